@@ -58,6 +58,17 @@ func AssertResNoErrT(t T, res interface{}, err error, asserts string) {
 }
 
 func Check(v interface{}, asserts string) Result {
+	return CheckWithOptions(v, asserts, nil)
+}
+
+// CheckWithOptions is like Check, but creates
+// the Object of v with opts
+func CheckWithOptions(v interface{}, asserts string, opts *Options) Result {
+	return CheckObject(rootObject(v, opts), asserts)
+}
+
+// CheckObject is like Check, but against an Object
+func CheckObject(obj Object, asserts string) Result {
 	asserter, err := ParseJSONAsserts(asserts)
 	if err != nil {
 		return Result{{BadSyntax: fmt.Sprintf("parsing assert: %v", err.Error())}}
@@ -67,8 +78,18 @@ func Check(v interface{}, asserts string) Result {
 			return Result{{NoAssert: true}}
 		}
 	}
-	return asserter.Check(v)
+	return asserter.CheckObject(obj)
+}
+
+// rootObject creates the Object of v, nil is a Null
+func rootObject(v interface{}, opts *Options) Object {
+	root := NewObjectWithOptions(v, opts)
+	if root == nil {
+		root = NewNull(v)
+	}
+	return root
 }
+
 func CheckOk(str string, v bool) Result {
 	if !v {
 		return Result{{Field: str, Expect: "true", Actual: "false"}}
@@ -110,7 +131,17 @@ func CheckResNoErr(res interface{}, err error, asserts string) Result {
 }
 
 func (c *Asserts) Check(v interface{}) Result {
-	root := NewObject(v)
+	return c.CheckWithOptions(v, nil)
+}
+
+// CheckWithOptions is like Check, but creates
+// the Object of v with opts
+func (c *Asserts) CheckWithOptions(v interface{}, opts *Options) Result {
+	return c.CheckObject(rootObject(v, opts))
+}
+
+// CheckObject is like Check, but against an Object
+func (c *Asserts) CheckObject(root Object) Result {
 	liveVals, res := c.filter.Filter([]Object{root}, root)
 	if !res.Ok() {
 		return res
@@ -218,7 +249,15 @@ type FailDetail struct {
 	Actual    string `json:"actual,omitempty"`
 	NoAssert  bool   `json:"no_assert,omitempty"`
 	BadSyntax string `json:"bad_syntax,omitempty"`
-	Str       string `json:"str"` // representation of this detail
+	// QueryError is set when the query fails, e.g. a method call fails
+	QueryError string `json:"query_error,omitempty"`
+	// ExpectNull and ActualNull tell a JSON null
+	// apart from the string "null"
+	ExpectNull bool `json:"expect_null,omitempty"`
+	ActualNull bool `json:"actual_null,omitempty"`
+	// Missing is set when nothing is found at Field
+	Missing bool   `json:"missing,omitempty"`
+	Str     string `json:"str"` // representation of this detail
 }
 
 func (c *FailDetail) MarshalJSON() ([]byte, error) {
@@ -232,10 +271,32 @@ func (c *FailDetail) MarshalJSON() ([]byte, error) {
 	return json.Marshal(wrap)
 }
 
+// Pointer returns Field as a JSON Pointer, e.g. orders.3.price
+// gives /orders/3/price, a trailing operator like $gt is dropped.
+// It is empty if Field is not a concrete path.
+func (c *FailDetail) Pointer() string {
+	field := c.Field
+	if node, err := parsePathAST(field); err == nil && len(node.segments) > 0 {
+		last := node.segments[len(node.segments)-1]
+		if last.kind == segKey && !last.exact && !last.regex && !last.call && len(last.conds) == 0 &&
+			strings.HasPrefix(last.key, "$") && !isPseudoPath(last.key) {
+			field = strings.TrimSuffix(field[:last.pos], ".")
+		}
+	}
+	ptr, err := PathToPointer(field)
+	if err != nil {
+		return ""
+	}
+	return ptr
+}
+
 func (c *FailDetail) String() string {
 	if c.BadSyntax != "" {
 		return fmt.Sprintf("bad syntax at %s: %s", c.Field, c.BadSyntax)
 	}
+	if c.QueryError != "" {
+		return fmt.Sprintf("query error at %s: %s", c.Field, c.QueryError)
+	}
 	if c.NoAssert {
 		return "no assert"
 	}
@@ -248,12 +309,35 @@ func (c *FailDetail) String() string {
 		}
 		return fmt.Sprintf("expect error: %s, actual: %v", c.Expect, c.Actual)
 	}
+	if c.Missing && c.Expect == "" && !c.ExpectNull {
+		return fmt.Sprintf("expect %s to exist, actual: %s", c.Field, c.actualString())
+	}
 	if c.Field != "" {
-		return fmt.Sprintf("expect %s to be %s, actual: %s", c.Field, strconv.Quote(c.Expect), strconv.Quote(c.Actual))
+		return fmt.Sprintf("expect %s to be %s, actual: %s", c.Field, c.expectString(), c.actualString())
 	}
-	if c.Expect != "" {
-		return fmt.Sprintf("expect %s, actual: %s", strconv.Quote(c.Expect), strconv.Quote(c.Actual))
+	if c.Expect != "" || c.ExpectNull {
+		return fmt.Sprintf("expect %s, actual: %s", c.expectString(), c.actualString())
 	}
 	// general fail message
 	return "fail"
 }
+
+// expectString quotes Expect, a JSON null is null
+func (c *FailDetail) expectString() string {
+	if c.ExpectNull {
+		return "null"
+	}
+	return strconv.Quote(c.Expect)
+}
+
+// actualString quotes Actual, a JSON null is null
+// and a missing value is <missing>
+func (c *FailDetail) actualString() string {
+	if c.Missing {
+		return "<missing>"
+	}
+	if c.ActualNull {
+		return "null"
+	}
+	return strconv.Quote(c.Actual)
+}
@@ -0,0 +1,81 @@
+package objpath
+
+import (
+	"container/list"
+	"sync"
+)
+
+// pathCacheSize is the max number of compiled
+// paths kept by QueryObjects
+const pathCacheSize = 1024
+
+var compiledPaths = newPathCache(pathCacheSize)
+
+// compilePathCached is like CompilePath, but reuses
+// previously compiled paths, paths failed to compile
+// are not cached.
+func compilePathCached(path string) (*Path, error) {
+	if p, ok := compiledPaths.Get(path); ok {
+		return p, nil
+	}
+	p, err := CompilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	compiledPaths.Add(path, p)
+	return p, nil
+}
+
+// pathCache is a LRU cache of compiled paths
+type pathCache struct {
+	mutex sync.Mutex
+	size  int
+	ll    *list.List // front is the most recently used
+	m     map[string]*list.Element
+}
+
+type pathCacheEntry struct {
+	key  string
+	path *Path
+}
+
+func newPathCache(size int) *pathCache {
+	return &pathCache{
+		size: size,
+		ll:   list.New(),
+		m:    make(map[string]*list.Element, size),
+	}
+}
+
+func (c *pathCache) Get(key string) (*Path, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e, ok := c.m[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*pathCacheEntry).path, true
+}
+
+func (c *pathCache) Add(key string, path *Path) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.m[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*pathCacheEntry).path = path
+		return
+	}
+	c.m[key] = c.ll.PushFront(&pathCacheEntry{key: key, path: path})
+	for c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.m, last.Value.(*pathCacheEntry).key)
+	}
+}
+
+func (c *pathCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,331 @@
+package objpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrimitiveKind is the kind of value a Primitive holds,
+// which decides how it is compared
+type PrimitiveKind int
+
+const (
+	KindString PrimitiveKind = iota
+	KindInt
+	KindUint
+	KindFloat
+	KindBool
+	KindTime
+	KindBytes
+	KindDuration
+)
+
+var primitiveKindNames = [...]string{
+	KindString:   "string",
+	KindInt:      "int",
+	KindUint:     "uint",
+	KindFloat:    "float",
+	KindBool:     "bool",
+	KindTime:     "time",
+	KindBytes:    "bytes",
+	KindDuration: "duration",
+}
+
+func (c PrimitiveKind) String() string {
+	if c >= 0 && int(c) < len(primitiveKindNames) {
+		return primitiveKindNames[c]
+	}
+	return "PrimitiveKind(" + strconv.Itoa(int(c)) + ")"
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// bigFloatPrec is the precision to compare
+// integers with decimals like 1.5 or 1e30
+const bigFloatPrec = 256
+
+// KindOf tells the kind of a primitive value, json.Number
+// is int if it is an integer literal, float otherwise.
+// Values of other kinds, including nil, are string.
+func KindOf(v interface{}) PrimitiveKind {
+	switch v := v.(type) {
+	case nil:
+		return KindString
+	case json.Number:
+		if _, ok := new(big.Int).SetString(string(v), 10); ok {
+			return KindInt
+		}
+		if _, err := strconv.ParseFloat(string(v), 64); err == nil {
+			return KindFloat
+		}
+		return KindString
+	case []byte:
+		return KindBytes
+	}
+	rv := reflect.ValueOf(v)
+	if _, ok := timeOfValue(rv); ok {
+		return KindTime
+	}
+	if rv.Type() == durationType {
+		return KindDuration
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return KindInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return KindUint
+	case reflect.Float32, reflect.Float64:
+		return KindFloat
+	case reflect.Bool:
+		return KindBool
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return KindBytes
+		}
+	}
+	return KindString
+}
+
+// comparePrimitive compares actual against expect by the kind
+// of actual: integers exactly, floats at their bit size, bools with
+// false < true, others lexically by StrValue. ok is false if
+// expect cannot be parsed as the kind of actual.
+func comparePrimitive(actual Primitive, expect string) (cmp int, ok bool) {
+	switch actual.Kind() {
+	case KindInt, KindUint:
+		a, ok := bigIntOf(actual.Value())
+		if !ok {
+			break
+		}
+		expect = strings.TrimSpace(expect)
+		if b, ok := new(big.Int).SetString(expect, 10); ok {
+			return a.Cmp(b), true
+		}
+		b, _, err := big.ParseFloat(expect, 10, bigFloatPrec, big.ToNearestEven)
+		if err != nil {
+			return 0, false
+		}
+		return new(big.Float).SetPrec(bigFloatPrec).SetInt(a).Cmp(b), true
+	case KindFloat:
+		a, bitSize, ok := floatOf(actual.Value())
+		if !ok {
+			break
+		}
+		// at the precision of actual, so that
+		// float32(0.1) equals 0.1
+		b, err := strconv.ParseFloat(strings.TrimSpace(expect), bitSize)
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			// b is ±Inf beyond the range of bitSize
+			err = nil
+		}
+		if err != nil || math.IsNaN(a) || math.IsNaN(b) {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case KindTime:
+		a, ok := timeOfValue(reflect.ValueOf(actual.Value()))
+		if !ok {
+			break
+		}
+		b, err := parseTime(expect)
+		if err != nil {
+			return 0, false
+		}
+		return compareTime(a, b), true
+	case KindDuration:
+		a, ok := durationOf(actual)
+		if !ok {
+			break
+		}
+		b, err := parseDuration(strings.TrimSpace(expect))
+		if err != nil {
+			return 0, false
+		}
+		return compareInt64(int64(a), int64(b)), true
+	case KindBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(expect))
+		if err != nil {
+			return 0, false
+		}
+		rv := reflect.ValueOf(actual.Value())
+		if rv.Kind() != reflect.Bool {
+			break
+		}
+		a := rv.Bool()
+		switch {
+		case a == b:
+			return 0, true
+		case b:
+			return -1, true
+		}
+		return 1, true
+	}
+	return strings.Compare(actual.StrValue(), expect), true
+}
+
+func compareTime(a time.Time, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func compareInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// timeLayouts are tried in order to parse expected times,
+// times without zone are in UTC
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTime parses s as RFC 3339, or a date time
+// or date without zone
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("parsing time: %v %v", s, err)
+}
+
+// timeOfValue is the time of time.Time or named types of it
+func timeOfValue(rv reflect.Value) (time.Time, bool) {
+	if !rv.IsValid() || rv.Kind() != reflect.Struct || !rv.Type().ConvertibleTo(timeType) {
+		return time.Time{}, false
+	}
+	return rv.Convert(timeType).Interface().(time.Time), true
+}
+
+// timeOf is the time of prim, strings like
+// RFC 3339 from JSON are parsed
+func timeOf(prim Primitive) (time.Time, bool) {
+	if t, ok := timeOfValue(reflect.ValueOf(prim.Value())); ok {
+		return t, true
+	}
+	if prim.Kind() != KindString {
+		return time.Time{}, false
+	}
+	t, err := parseTime(prim.StrValue())
+	return t, err == nil
+}
+
+// durationOf is the duration of prim, strings
+// like 1m30s from JSON are parsed
+func durationOf(prim Primitive) (time.Duration, bool) {
+	rv := reflect.ValueOf(prim.Value())
+	if rv.IsValid() && rv.Type() == durationType {
+		return time.Duration(rv.Int()), true
+	}
+	if prim.Kind() != KindString {
+		return 0, false
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(prim.StrValue()))
+	return d, err == nil
+}
+
+func bigIntOf(v interface{}) (*big.Int, bool) {
+	if n, ok := v.(json.Number); ok {
+		return new(big.Int).SetString(string(n), 10)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	}
+	return nil, false
+}
+
+// floatOf returns v as float64 and the bit size of v
+func floatOf(v interface{}) (f float64, bitSize int, ok bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, 64, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), rv.Type().Bits(), true
+	}
+	return 0, 0, false
+}
+
+// CheckPrimitive is like Check, but compares by the kind
+// of actual, e.g. 1 equals 1.0 for numbers, and strings
+// are ordered lexically. StrValue equal to expect is always
+// equal, e.g. with Options.FormatFloat. Operators on strings
+// like $contains apply to StrValue. $before and $after
+// compare times, including RFC 3339 strings.
+func (c Op) CheckPrimitive(actual Primitive, expect string) bool {
+	switch c {
+	case OpEq, OpNeq, OpLt, OpLe, OpGt, OpGe:
+	case OpBefore, OpAfter:
+		a, ok := timeOf(actual)
+		if !ok {
+			return false
+		}
+		b, err := parseTime(expect)
+		if err != nil {
+			return false
+		}
+		if c == OpBefore {
+			return a.Before(b)
+		}
+		return a.After(b)
+	default:
+		return c.Check(actual.StrValue(), expect)
+	}
+	cmp, ok := comparePrimitive(actual, expect)
+	if !ok {
+		return c.Check(actual.StrValue(), expect)
+	}
+	if actual.StrValue() == expect {
+		cmp = 0
+	}
+	switch c {
+	case OpEq:
+		return cmp == 0
+	case OpNeq:
+		return cmp != 0
+	case OpLt:
+		return cmp < 0
+	case OpLe:
+		return cmp <= 0
+	case OpGt:
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
@@ -0,0 +1,237 @@
+package objpath
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test -run TestKindOf -v ./
+func TestKindOf(t *testing.T) {
+	cases := []struct {
+		v      interface{}
+		expect PrimitiveKind
+	}{
+		{"x", KindString},
+		{testNamedString("x"), KindString},
+		{1, KindInt},
+		{int8(-1), KindInt},
+		{uint64(1), KindUint},
+		{1.5, KindFloat},
+		{float32(1.5), KindFloat},
+		{true, KindBool},
+		{json.Number("12345678901234567890"), KindInt},
+		{json.Number("1.5e3"), KindFloat},
+		{time.Time{}, KindTime},
+		{[]byte("x"), KindBytes},
+		{nil, KindString},
+	}
+	for i, c := range cases {
+		kind := KindOf(c.v)
+		if kind != c.expect {
+			t.Fatalf("case %d %T: expect %s = %+v, actual:%+v", i, c.v, `kind`, c.expect, kind)
+		}
+	}
+}
+
+// go test -run TestCheckPrimitive -v ./
+func TestCheckPrimitive(t *testing.T) {
+	prim := func(v interface{}) Primitive {
+		return NewObject(v).(Primitive)
+	}
+	cases := []struct {
+		actual Primitive
+		op     Op
+		expect string
+		ok     bool
+	}{
+		// integers beyond 2^53 are exact
+		{prim(int64(9007199254740993)), OpGt, "9007199254740992", true},
+		{prim(int64(9007199254740993)), OpEq, "9007199254740992", false},
+		{prim(uint64(math.MaxUint64)), OpEq, "18446744073709551615", true},
+		{prim(uint64(math.MaxUint64)), OpGt, "18446744073709551614", true},
+		{prim(json.Number("12345678901234567891")), OpGt, "12345678901234567890", true},
+		{prim(int64(math.MinInt64)), OpLt, "-9223372036854775807", true},
+		// numbers of different forms
+		{prim(1), OpEq, "1.0", true},
+		{prim(1), OpEq, "1e0", true},
+		{prim(3), OpLt, "3.5", true},
+		{prim(3), OpGe, "2.5", true},
+		{prim(1.0), OpEq, "1", true},
+		{prim(float32(0.5)), OpEq, "0.50", true},
+		// float32 at its own precision
+		{prim(float32(0.1)), OpEq, "0.10", true},
+		{prim(float32(0.1)), OpGt, "0.10", false},
+		{prim(float32(0.1)), OpLt, "0.10", false},
+		{prim(float32(0.1)), OpLt, "0.11", true},
+		{prim(float32(0.1)), OpGt, "0.09", true},
+		{prim(float32(16777216)), OpEq, "16777217", true},
+		{prim(float32(1)), OpLt, "1e40", true},
+		{prim(0.1), OpEq, "0.10", true},
+		{prim(0.1), OpGt, "0.1000000000000000001", false},
+		{prim(2.5), OpNeq, "2.50", false},
+		{prim(json.Number("1.50")), OpEq, "1.5", true},
+		{prim(math.NaN()), OpEq, "NaN", true},
+		{prim(math.NaN()), OpLt, "1", false},
+		// strings are ordered lexically
+		{prim("apple"), OpLt, "banana", true},
+		{prim("b"), OpGe, "abc", true},
+		{prim("10"), OpLt, "9", true},
+		{prim("10"), OpGt, "9", false},
+		{prim("10"), OpGe, "9", false},
+		{prim("1.0"), OpEq, "1", false},
+		// bools
+		{prim(true), OpEq, "true", true},
+		{prim(false), OpLt, "true", true},
+		{prim(true), OpNeq, "false", true},
+		// not comparable falls back to strings
+		{prim(1), OpEq, "one", false},
+		{prim(1), OpNeq, "one", true},
+		{prim(12), OpContains, "2", true},
+	}
+	for i, c := range cases {
+		ok := c.op.CheckPrimitive(c.actual, c.expect)
+		if ok != c.ok {
+			t.Fatalf("case %d %v %s %s: expect %s = %+v, actual:%+v", i, c.actual.Value(), c.op, c.expect, `ok`, c.ok, ok)
+		}
+	}
+}
+
+// go test -run TestFilterTypedCompare -v ./
+func TestFilterTypedCompare(t *testing.T) {
+	v := map[string]interface{}{
+		"id":    int64(9007199254740993),
+		"price": 10.0,
+		"name":  "beta",
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "price": 1},
+			map[string]interface{}{"name": "b", "price": 2.5},
+		},
+	}
+	Check(v, `{
+		"id":{"$gt":"9007199254740992"},
+		"price":10,
+		"name":{"$gt":"alpha","$lt":"gamma"},
+		"items.*{price>1.5}.name":"b",
+		"items.*{price=1.0}.name":"a",
+		"items.*{name<b}.price":"1"
+	}`).VerifyT(t)
+	if Check(v, `{"id":"9007199254740992"}`).Ok() {
+		t.Fatalf("expect %s = %+v, actual:%+v", `ok`, false, true)
+	}
+
+	// numeric strings are ordered lexically, not as numbers
+	w := map[string]interface{}{"version": "10", "count": 10}
+	Check(w, `{"version":{"$lt":"9"},"count":{"$gt":"9"}}`).VerifyT(t)
+	if s := Check(w, `{"version":{"$gt":"9"}}`).String(); s != `expect version.$gt to be "9", actual: "10"` {
+		t.Fatalf("expect %s = %+v, actual:%+v", `s`, `expect version.$gt to be "9", actual: "10"`, s)
+	}
+}
+
+type testBytes []byte
+
+// go test -run TestBytesPrimitive -v ./
+func TestBytesPrimitive(t *testing.T) {
+	v := map[string]interface{}{
+		"x": []byte("ab"),
+		"y": testBytes("cd"),
+		"z": []byte(nil),
+	}
+	for _, key := range []string{"x", "y"} {
+		p, ok := NewObject(v[key]).(Primitive)
+		if !ok || p.Kind() != KindBytes {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", key, `kind`, KindBytes, p)
+		}
+	}
+	Check(v, `{"x":"ab","y":{"$gt":"cc"},"z":"","x.$length":2,"x.$type":"string"}`).VerifyT(t)
+	s := Check(v, `{"x":"ba"}`).String()
+	expect := `expect x to be "ba", actual: "ab"`
+	if s != expect {
+		t.Fatalf("expect %s = %+v, actual:%+v", `s`, expect, s)
+	}
+}
+
+type testEvent struct {
+	Name    string
+	At      time.Time
+	Expire  *time.Time
+	Timeout time.Duration
+}
+
+// go test -run TestTimePrimitives -v ./
+func TestTimePrimitives(t *testing.T) {
+	at := time.Date(2024, 3, 1, 10, 0, 0, 500, time.UTC)
+	v := &testEvent{Name: "x", At: at, Expire: &at, Timeout: 90 * time.Second}
+	for path, expect := range map[string]string{
+		"At":            "[2024-03-01T10:00:00.0000005Z]",
+		"Expire":        "[2024-03-01T10:00:00.0000005Z]",
+		"Timeout":       "[1m30s]",
+		"At.$type":      "[string]",
+		"*{Timeout>1m}": "[]",
+	} {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := strValues(res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `res`, expect, s)
+		}
+	}
+	if kind := NewObject(at).(Primitive).Kind(); kind != KindTime {
+		t.Fatalf("expect %s = %+v, actual:%+v", `kind`, KindTime, kind)
+	}
+	if kind := NewObject(time.Second).(Primitive).Kind(); kind != KindDuration {
+		t.Fatalf("expect %s = %+v, actual:%+v", `kind`, KindDuration, kind)
+	}
+	Check(v, `{
+		"At":"2024-03-01T10:00:00.0000005Z",
+		"Timeout":"90s",
+		"Expire":{"$gt":"2024-03-01","$lt":"2024-03-01T11:00:01+01:00"}
+	}`).VerifyT(t)
+	Check(v, `{"Timeout":90000000000}`).VerifyT(t)
+	Check(v, `{"Timeout":{"$gt":"1m","$le":"1m30s"}}`).VerifyT(t)
+}
+
+// go test -run TestFilterTimeOperators -v ./
+func TestFilterTimeOperators(t *testing.T) {
+	now := time.Now()
+	v := map[string]interface{}{
+		"now":     now,
+		"created": now.Add(-2 * time.Second),
+		"expire":  now.Add(time.Hour),
+		// from JSON
+		"updated": "2024-03-01T10:00:00Z",
+		"elapsed": 1500 * time.Millisecond,
+		"limit":   2 * time.Second,
+	}
+	for _, assert := range []string{
+		`{"created":{"$before":"$.now"}}`,
+		`{"expire":{"$after":"$.now","$before":"9999-01-01"}}`,
+		`{"updated":{"$after":"2024-02-29","$before":"2024-03-01T10:00:01Z"}}`,
+		`{"created":{"$within":"5s","$of":"$.now"}}`,
+		`{"created":{"$within":"5s"}}`,
+		`{"updated":{"$within":"1h","$of":"2024-03-01T10:30:00Z"}}`,
+		`{"elapsed":{"$within":"500ms","$of":"$.limit"}}`,
+		`{"elapsed":{"$within":"100ms","$of":"1.4s"}}`,
+		`{"*{$type=string}":{"$after":"2024-01-01"}}`,
+	} {
+		Check(v, assert).VerifyT(t)
+	}
+	for assert, expect := range map[string]string{
+		`{"created":{"$after":"$.now"}}`:                `expect created.$after to be `,
+		`{"created":{"$within":"1s","$of":"$.now"}}`:    `expect created.$within to be "within 1s of $.now", actual: `,
+		`{"expire":{"$within":"5s"}}`:                   `expect expire.$within to be "within 5s of now", actual: `,
+		`{"elapsed":{"$within":"100ms","$of":"1s"}}`:    `expect elapsed.$within to be "within 100ms of 1s", actual: "1.5s"`,
+		`{"updated":{"$within":"forever"}}`:             `bad syntax at updated.$within: $within expects a duration`,
+		`{"updated":{"$within":"1s","$of":"tomorrow"}}`: `bad syntax at updated.$within: $of expects a time`,
+		`{"updated":{"$of":"$.now"}}`:                   `bad syntax at updated.$of: $of must be used with $within`,
+	} {
+		s := Check(v, assert).String()
+		if !strings.HasPrefix(s, expect) {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", assert, `s`, expect, s)
+		}
+	}
+}
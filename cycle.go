@@ -0,0 +1,165 @@
+package objpath
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Ref is a child that refers back to one of its ancestors, like
+// a back-pointer from child to parent, so that walking all
+// descendants terminates. Keys are still looked up through
+// Target, e.g. items.0.Order.ID, but wildcards and recursive
+// descent do not go through a Ref.
+type Ref struct {
+	val    interface{}
+	target Object
+	// view is created from val like target, its
+	// children are located under the Ref
+	view Object
+	once sync.Once
+	loc
+}
+
+var _ Composite = ((*Ref)(nil))
+
+// Target is the ancestor that the Ref refers to
+func (c *Ref) Target() Object {
+	return c.target
+}
+
+// TargetPath is the path of Target from the top level
+// object, e.g. $ or $.items.0
+func (c *Ref) TargetPath() string {
+	keys, _ := LocationOf(RootOf(c.target), c.target)
+	if len(keys) == 0 {
+		return "$"
+	}
+	return "$." + JoinPath(keys)
+}
+
+// Value implements Object
+func (c *Ref) Value() interface{} {
+	return c.val
+}
+
+// Method implements Object
+func (c *Ref) Method(name string) (method interface{}, ok bool) {
+	return c.target.Method(name)
+}
+
+// ChildrenLen implements Composite
+func (c *Ref) ChildrenLen() int {
+	return c.composite().ChildrenLen()
+}
+
+// RangeChildren implements Composite
+func (c *Ref) RangeChildren(fn func(key string, child Object) bool) {
+	c.composite().RangeChildren(fn)
+}
+
+// GetChild implements Composite
+func (c *Ref) GetChild(key string) (child Object, ok bool) {
+	return c.composite().GetChild(key)
+}
+
+// composite is the view whose children are located
+// under the Ref, so that failures under a cycle are
+// reported at paths like items.0.Order.ID
+func (c *Ref) composite() Composite {
+	comp := c.view.(Composite)
+	c.once.Do(func() {
+		comp.RangeChildren(func(key string, child Object) bool {
+			if l, ok := child.(locatable); ok {
+				l.setLocation(c, key)
+			}
+			return true
+		})
+	})
+	return comp
+}
+
+func (c *Ref) String() string {
+	return fmt.Sprintf("<cycle at %s>", c.TargetPath())
+}
+
+// IsRef tells whether obj is a Ref
+func IsRef(obj Object) bool {
+	_, ok := obj.(*Ref)
+	return ok
+}
+
+// deref returns what a Ref refers to, with children
+// located under the Ref, or obj itself
+func deref(obj Object) Object {
+	if ref, ok := obj.(*Ref); ok {
+		return ref.composite()
+	}
+	return obj
+}
+
+// asList is obj or the target of obj as list
+func asList(obj Object) (listObject, bool) {
+	list, ok := deref(obj).(listObject)
+	return list, ok
+}
+
+// identity tells whether two Objects are created from
+// the same memory, zero for values that cannot be shared
+type identity struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+func identityOf(rv reflect.Value) identity {
+	if rv.Type().Size() == 0 {
+		// zero sized values may share address
+		return identity{}
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.IsNil() {
+			return identity{}
+		}
+		return identity{typ: rv.Type(), ptr: rv.Pointer()}
+	case reflect.Slice:
+		if rv.IsNil() || rv.Len() == 0 {
+			return identity{}
+		}
+		return identity{typ: rv.Type(), ptr: rv.Pointer(), len: rv.Len()}
+	case reflect.Struct, reflect.Array:
+		if rv.CanAddr() {
+			return identity{typ: rv.Type(), ptr: rv.UnsafeAddr()}
+		}
+	}
+	return identity{}
+}
+
+// identified is implemented by Struct, Map and List
+type identified interface {
+	identity() identity
+}
+
+func (c *base) identity() identity {
+	return c.ident
+}
+
+// cycleTarget returns the ancestor from parent up that
+// has the same identity as child, nil if none
+func cycleTarget(parent Object, child Object) Object {
+	ci, ok := child.(identified)
+	if !ok {
+		return nil
+	}
+	id := ci.identity()
+	if id.typ == nil {
+		return nil
+	}
+	for p := parent; p != nil; p = ParentOf(p) {
+		if pi, ok := p.(identified); ok && pi.identity() == id {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package objpath
+
+import (
+	"testing"
+)
+
+type testTree struct {
+	Name     string
+	Parent   *testTree
+	Children []*testTree
+}
+
+func newTestTree() *testTree {
+	root := &testTree{Name: "root"}
+	a := &testTree{Name: "a", Parent: root}
+	b := &testTree{Name: "b", Parent: a}
+	a.Children = []*testTree{b}
+	root.Children = []*testTree{a}
+	return root
+}
+
+// go test -run TestQueryCycle -v ./
+func TestQueryCycle(t *testing.T) {
+	root := newTestTree()
+	for path, expect := range map[string]string{
+		"$..Name":                           "[root a b]",
+		"Children.0.Parent":                 "[<cycle at $>]",
+		"Children.0.Parent.Name":            "[root]",
+		"Children.0.Children.0.Parent":      "[<cycle at $.Children.0>]",
+		"Children.*.Children.*.Name":        "[b]",
+		"$..{Name=b}.Parent.Parent.Name":    "[root]",
+		"$..Parent.$type":                   "[null struct struct]",
+		"Children.0.Parent.Children.0.Name": "[a]",
+	} {
+		res, err := Query(root, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := strValues(res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `res`, expect, s)
+		}
+	}
+
+	// a map containing itself
+	m := map[string]interface{}{"k": 1}
+	m["self"] = m
+	res, err := Query(m, "$..k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(res); s != "[1]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[1]", s)
+	}
+	res, err = Query(m, "self")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || !IsRef(res[0]) || res[0].(*Ref).Target() == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[<cycle at $>]", res)
+	}
+	jp, err := QueryJSONPath(m, "$..k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(jp); s != "[1]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `jp`, "[1]", s)
+	}
+	Check(root, `{"$..{Name=b}":{"Parent.Name":"a"}}`).VerifyT(t)
+}
+
+// go test -run TestSharedIsNotCycle -v ./
+func TestSharedIsNotCycle(t *testing.T) {
+	shared := &testTree{Name: "s"}
+	v := map[string]interface{}{
+		"a": shared,
+		"b": shared,
+		"l": []*testTree{shared, shared},
+	}
+	res, err := Query(v, "$..Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(res); s != "[s s s s]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[s s s s]", s)
+	}
+}
+
+// go test -run TestCycleFailurePath -v ./
+func TestCycleFailurePath(t *testing.T) {
+	root := newTestTree()
+	for assert, expect := range map[string]string{
+		`{"Children.0.Parent.Name":"x"}`:                         `expect Children.0.Parent.Name to be "x", actual: "root"`,
+		`{"Children.0.Parent.Children.0.Name":"x"}`:              `expect Children.0.Parent.Children.0.Name to be "x", actual: "a"`,
+		`{"Children.0.Children.0.Parent.Children[-1].Name":"x"}`: `expect Children.0.Children.0.Parent.Children.0.Name to be "x", actual: "b"`,
+	} {
+		s := Check(root, assert).String()
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", assert, `s`, expect, s)
+		}
+	}
+
+	res, err := QueryWithPaths(root, "Children.0.Parent.Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || JoinPath(res[0].Path) != "Children.0.Parent.Name" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `path`, "Children.0.Parent.Name", res)
+	}
+	parent, err := Query(root, "Children.0.Parent.Name.^")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parent) != 1 || !IsRef(parent[0]) {
+		t.Fatalf("expect %s = %+v, actual:%+v", `parent`, "<cycle at $>", parent)
+	}
+}
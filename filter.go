@@ -3,9 +3,11 @@ package objpath
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -30,14 +32,50 @@ type ObjectFilter interface {
 
 type StringAssert string
 
+// NullAssert is a JSON null in asserts, it holds for
+// null values, not for missing ones
+type NullAssert struct{}
+
+// Filter implements ObjectFilter
+func (c NullAssert) Filter(v []Object, root Object) ([]Object, Result) {
+	var res []Object
+	var errRes Result
+	for _, o := range v {
+		if IsNull(o) {
+			res = append(res, o)
+			continue
+		}
+		errRes.Append(&FailDetail{
+			Expect:     "null",
+			ExpectNull: true,
+			Actual:     actualString(o),
+			ActualNull: IsNull(o),
+		})
+	}
+	if len(res) > 0 {
+		// clear debug errors
+		errRes = nil
+	}
+	return res, errRes
+}
+
+// actualString describes o in failure details
+func actualString(o Object) string {
+	if IsNull(o) {
+		return "null"
+	}
+	if prim, ok := o.(Primitive); ok {
+		return prim.StrValue()
+	}
+	return "<object>"
+}
+
 // Check implements Assert
 func (c StringAssert) Filter(v []Object, root Object) ([]Object, Result) {
-	return filterPrimitive(string(c), v, root, func(curVal, incomingVal string) bool {
-		return curVal == incomingVal
-	})
+	return filterPrimitive(string(c), v, root, OpEq.CheckPrimitive)
 }
 
-func filterPrimitive(expectVal string, actualVals []Object, root Object, check func(actualVal string, expectVal string) bool) ([]Object, Result) {
+func filterPrimitive(expectVal string, actualVals []Object, root Object, check func(actual Primitive, expectVal string) bool) ([]Object, Result) {
 	if len(actualVals) == 0 {
 		return nil, nil
 	}
@@ -46,7 +84,7 @@ func filterPrimitive(expectVal string, actualVals []Object, root Object, check f
 		path := expectVal[len("$."):]
 		objs, err := QueryObject(root, path)
 		if err != nil {
-			return nil, Result{{BadSyntax: fmt.Sprintf("query path:%v %v", expectVal, err)}}
+			return nil, Result{queryFail("", err)}
 		}
 		// no value found,return nil
 		if len(objs) == 0 {
@@ -73,7 +111,7 @@ func filterPrimitive(expectVal string, actualVals []Object, root Object, check f
 				}
 				primStr := prim.StrValue()
 				objPrimStr := objPrim.StrValue()
-				if !check(primStr, objPrimStr) {
+				if !check(prim, objPrimStr) {
 					matchAll = false
 					errRes.Append(&FailDetail{
 						Expect: objPrimStr,
@@ -98,19 +136,16 @@ func filterPrimitive(expectVal string, actualVals []Object, root Object, check f
 		prim, ok := o.(Primitive)
 		if !ok {
 			// speical properties like $length
-			act := "<object>"
-			if o == nil {
-				act = "null"
-			}
 			errRes.Append(&FailDetail{
-				Expect: expectVal,
-				Actual: act,
+				Expect:     expectVal,
+				Actual:     actualString(o),
+				ActualNull: IsNull(o),
 			})
 			continue
 		}
 		actVal := prim.StrValue()
 
-		if check(actVal, expectVal) {
+		if check(prim, expectVal) {
 			res = append(res, o)
 		} else {
 			errRes.Append(&FailDetail{
@@ -175,8 +210,11 @@ func (c CompositeFilter) Filter(v []Object, root Object) ([]Object, Result) {
 			}
 			var childErrRes Result
 			var objsByOp []Object
-			//  special
-			if key[0] == '$' {
+			var missing bool
+			//  special, pseudo properties like $length
+			// and paths from root like $.a are queried
+			// as normal paths
+			if key[0] == '$' && !isPseudoPath(key) && !isRootPath(key) {
 				// for special assert, the value must be a string
 				// otherwise
 				expectVal, ok := expectFilter.(StringAssert)
@@ -188,38 +226,44 @@ func (c CompositeFilter) Filter(v []Object, root Object) ([]Object, Result) {
 					match = false
 					break
 				}
-				// take special properties
-				argActVal := actVal
 				op := Op(key)
-				if key == "$length" {
-					op = "$eq"
-					switch e := actVal.(type) {
-					case Primitive:
-						n := len(e.StrValue())
-						argActVal = NewPrimitve(n, strconv.FormatInt(int64(n), 10))
-					case Composite:
-						argActVal = NewPrimitve(e.ChildrenLen(), strconv.FormatInt(int64(e.ChildrenLen()), 10))
+				if op == OpOf {
+					// used by $within
+					if _, ok := c[string(OpWithin)]; ok {
+						continue
 					}
+					errRes.Append(&FailDetail{
+						Field:     key,
+						BadSyntax: fmt.Sprintf("%s must be used with %s", OpOf, OpWithin),
+					})
+					match = false
+					break
+				}
+				if op == OpExists || op == OpNull {
+					objsByOp, childErrRes = filterPresence(op, string(expectVal), actVal)
+				} else if op == OpWithin {
+					of, _ := c[string(OpOf)].(StringAssert)
+					objsByOp, childErrRes = filterWithin(string(expectVal), string(of), actVal, root)
+				} else {
+					objsByOp, childErrRes = filterPrimitive(string(expectVal), []Object{actVal}, root, op.CheckPrimitive)
 				}
-				objsByOp, childErrRes = filterPrimitive(string(expectVal), []Object{argActVal}, root, op.Check)
 			} else {
 				var objs []Object
 				var qerr error
 				objs, qerr = QueryObject(actVal, key)
 				if qerr != nil {
-					errRes.Append(&FailDetail{
-						Field:     key,
-						BadSyntax: fmt.Sprintf("query path:%v %v", key, qerr),
-					})
+					errRes.Append(queryFail(key, qerr))
 					match = false
 					break
 				}
-				objsByOp, childErrRes = expectFilter.Filter(objs, root)
+				if len(objs) == 0 && expectMissing(expectFilter) {
+					continue
+				}
+				missing = len(objs) == 0
+				objsByOp, childErrRes = filterEach(expectFilter, objs, actVal, key, root)
 			}
 			for _, childErr := range childErrRes {
-				if childErr.Field != "" {
-					childErr.Field = key + "." + childErr.Field
-				} else {
+				if childErr.Field == "" {
 					childErr.Field = key
 				}
 			}
@@ -228,9 +272,14 @@ func (c CompositeFilter) Filter(v []Object, root Object) ([]Object, Result) {
 			if len(objsByOp) == 0 {
 				if childErrRes.Ok() {
 					// if no child res, add reason
-					errRes.Append(&FailDetail{
+					detail := &FailDetail{
 						Field: key,
-					})
+					}
+					if missing {
+						detail.Missing = true
+						detail.Expect, detail.ExpectNull = expectOf(expectFilter)
+					}
+					errRes.Append(detail)
 				}
 				match = false
 				break
@@ -251,6 +300,169 @@ func (c CompositeFilter) Filter(v []Object, root Object) ([]Object, Result) {
 	return objRes, errRes
 }
 
+// filterEach filters objs found by querying key from `from`
+// one by one, so that failures are reported at the
+// concrete path of each object, e.g. orders.3.items.0.price
+// instead of orders.*.items.*.price
+func filterEach(filter ObjectFilter, objs []Object, from Object, key string, root Object) ([]Object, Result) {
+	var res []Object
+	var errRes Result
+	for _, obj := range objs {
+		matched, objErrRes := filter.Filter([]Object{obj}, root)
+		res = append(res, matched...)
+		field := fieldOf(from, obj, key)
+		for _, objErr := range objErrRes {
+			if objErr.Field == "" {
+				objErr.Field = field
+			} else if !isRootPath(objErr.Field) {
+				objErr.Field = field + "." + objErr.Field
+			}
+		}
+		errRes.Append(objErrRes...)
+	}
+	if len(res) > 0 {
+		// clear debug errors
+		errRes = nil
+	}
+	return res, errRes
+}
+
+// expectOf describes what filter expects of a single value,
+// to report missing keys. It is empty for composite filters.
+func expectOf(filter ObjectFilter) (expect string, isNull bool) {
+	switch filter := filter.(type) {
+	case StringAssert:
+		return string(filter), false
+	case NullAssert:
+		return "null", true
+	}
+	return "", false
+}
+
+// expectMissing tells whether filter is {"$exists":false},
+// which holds when the key is missing
+func expectMissing(filter ObjectFilter) bool {
+	m, ok := filter.(CompositeFilter)
+	if !ok {
+		return false
+	}
+	exists, ok := m[string(OpExists)].(StringAssert)
+	return ok && strings.TrimSpace(string(exists)) == "false"
+}
+
+// filterPresence checks $exists and $null against obj, which
+// is present since it is found. Missing keys are handled
+// by CompositeFilter with expectMissing.
+func filterPresence(op Op, expectVal string, obj Object) ([]Object, Result) {
+	want, err := strconv.ParseBool(strings.TrimSpace(expectVal))
+	if err != nil {
+		return nil, Result{{BadSyntax: fmt.Sprintf("%s expects true or false, found %q", op, expectVal)}}
+	}
+	if op == OpExists {
+		if want {
+			return []Object{obj}, nil
+		}
+		return nil, Result{{Expect: "not exists", Actual: actualString(obj), ActualNull: IsNull(obj)}}
+	}
+	if IsNull(obj) == want {
+		return []Object{obj}, nil
+	}
+	expect := "null"
+	if !want {
+		expect = "not null"
+	}
+	return nil, Result{{Expect: expect, Actual: actualString(obj), ActualNull: IsNull(obj)}}
+}
+
+// filterWithin checks that obj, a time or duration, differs
+// from of by at most within, of is a literal or a path from
+// root like $.now. An empty of means now for times.
+func filterWithin(within string, of string, obj Object, root Object) ([]Object, Result) {
+	tolerance, err := parseDuration(strings.TrimSpace(within))
+	if err != nil {
+		return nil, Result{{BadSyntax: fmt.Sprintf("%s expects a duration: %v", OpWithin, err)}}
+	}
+	ofDesc := of
+	if ofDesc == "" {
+		ofDesc = "now"
+	}
+	fail := Result{{Expect: fmt.Sprintf("within %s of %s", within, ofDesc), Actual: actualString(obj), ActualNull: IsNull(obj)}}
+	prim, ok := obj.(Primitive)
+	if !ok {
+		return nil, fail
+	}
+	var ofPrim Primitive
+	if strings.HasPrefix(of, "$.") {
+		objs, err := QueryObject(root, of[len("$."):])
+		if err != nil {
+			return nil, Result{queryFail("", err)}
+		}
+		if len(objs) == 0 {
+			return nil, fail
+		}
+		if ofPrim, ok = objs[0].(Primitive); !ok {
+			return nil, fail
+		}
+	}
+
+	var diff time.Duration
+	if t, isTime := timeOf(prim); isTime {
+		ofTime := time.Now()
+		if ofPrim != nil {
+			if ofTime, ok = timeOf(ofPrim); !ok {
+				return nil, fail
+			}
+		} else if of != "" {
+			if ofTime, err = parseTime(of); err != nil {
+				return nil, Result{{BadSyntax: fmt.Sprintf("%s expects a time: %v", OpOf, err)}}
+			}
+		}
+		diff = t.Sub(ofTime)
+	} else if d, isDuration := durationOf(prim); isDuration {
+		var ofDuration time.Duration
+		if ofPrim != nil {
+			if ofDuration, ok = durationOf(ofPrim); !ok {
+				return nil, fail
+			}
+		} else if ofDuration, err = parseDuration(strings.TrimSpace(of)); err != nil {
+			return nil, Result{{BadSyntax: fmt.Sprintf("%s expects a duration: %v", OpOf, err)}}
+		}
+		diff = d - ofDuration
+	} else {
+		return nil, fail
+	}
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > tolerance {
+		return nil, fail
+	}
+	return []Object{obj}, nil
+}
+
+// isRootPath tells whether path starts with
+// the root segment, like $, $.a, $[0]
+func isRootPath(path string) bool {
+	return path == "$" || (len(path) > 1 && path[0] == '$' && strings.IndexByte(".[{", path[1]) >= 0)
+}
+
+// queryFail describes a failed query, a *PathSyntaxError
+// is reported as bad syntax with the error position annotated,
+// other errors like a failed method call as query error
+func queryFail(field string, err error) *FailDetail {
+	var syntaxErr *PathSyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &FailDetail{
+			Field:     field,
+			BadSyntax: fmt.Sprintf("query path: %v", syntaxErr),
+		}
+	}
+	return &FailDetail{
+		Field:      field,
+		QueryError: err.Error(),
+	}
+}
+
 type Op string
 
 const (
@@ -263,6 +475,20 @@ const (
 	OpContains   Op = "$contains"
 	OpStartsWith Op = "$startsWith"
 	OpEndsWith   Op = "$endsWith"
+	// OpExists $exists:true holds if the key is present,
+	// even if null, $exists:false holds if it is missing
+	OpExists Op = "$exists"
+	// OpNull $null:true holds if the value is null
+	OpNull Op = "$null"
+	// OpBefore and OpAfter compare times,
+	// e.g. {"$after":"2024-01-01T00:00:00Z"}
+	OpBefore Op = "$before"
+	OpAfter  Op = "$after"
+	// OpWithin holds if the time or duration differs from $of
+	// by at most the given duration, $of defaults to now for
+	// times, e.g. {"$within":"5s","$of":"$.now"}
+	OpWithin Op = "$within"
+	OpOf     Op = "$of"
 )
 
 func (c Op) Check(curVal string, incomingVal string) bool {
@@ -348,7 +574,7 @@ func ParseJSONFilterBytes(data []byte) (ObjectFilter, error) {
 
 func build(m interface{}) (ObjectFilter, error) {
 	if m == nil {
-		return nil, nil
+		return NullAssert{}, nil
 	}
 
 	switch m := m.(type) {
@@ -4,13 +4,13 @@ import (
 	"testing"
 )
 
-type Options string
+type testOption string
 
 const (
-	OptionFail Options = "fail"
+	OptionFail testOption = "fail"
 )
 
-func testAssert(t *testing.T, obj interface{}, filter string, assert string, opts ...Options) {
+func testAssert(t *testing.T, obj interface{}, filter string, assert string, opts ...testOption) {
 	jsonFilter, err := parseJSONFilter(filter)
 	if err != nil {
 		t.Fatal(err)
@@ -28,7 +28,8 @@ func testAssert(t *testing.T, obj interface{}, filter string, assert string, opt
 	if needPass {
 		AssertOkT(t, "res ok", res.Ok())
 	} else {
-		AssertNotOkT(t, "res ok", !res.Ok())
+		// OptionFail expects the filter itself to fail
+		AssertNotOkT(t, "res ok", res.Ok())
 	}
 	AssertT(t, liveVals, assert)
 }
@@ -322,3 +323,168 @@ func TestFilterFlattenWildcardOnlyOneForMultiFields(t *testing.T) {
 		}`,
 	)
 }
+
+// go test -run TestFilterRecursiveDescent -v ./
+func TestFilterRecursiveDescent(t *testing.T) {
+	testAssert(t,
+		map[string]interface{}{
+			"a": map[string]interface{}{
+				"b": map[string]interface{}{
+					"c": map[string]interface{}{
+						"id": "deep",
+					},
+				},
+			},
+		},
+		`{
+			"a..id":"deep"
+		}`,
+		`{
+			"$length":"1"
+		}`,
+	)
+}
+
+// go test -run TestFilterLastElement -v ./
+func TestFilterLastElement(t *testing.T) {
+	testAssert(t,
+		map[string]interface{}{
+			"events": []interface{}{
+				map[string]interface{}{"type": "start"},
+				map[string]interface{}{"type": "done"},
+			},
+		},
+		`{
+			"events[-1].type":"done"
+		}`,
+		`{
+			"$length":"1"
+		}`,
+	)
+}
+
+// go test -run TestFilterUnionSiblings -v ./
+func TestFilterUnionSiblings(t *testing.T) {
+	testAssert(t,
+		map[string]interface{}{
+			"user": map[string]interface{}{
+				"name":  "alice",
+				"email": "alice@example.com",
+			},
+		},
+		`{
+			"user.[name,email]":{
+				"$startsWith":"alice"
+			}
+		}`,
+		`{
+			"$length":"1"
+		}`,
+	)
+}
+
+// go test -run TestFilterRootAndParentKeys -v ./
+func TestFilterRootAndParentKeys(t *testing.T) {
+	testAssert(t,
+		map[string]interface{}{
+			"customer": "ada",
+			"order": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"name": "a", "qty": 1},
+				},
+			},
+		},
+		`{
+			"order.items[0]":{
+				"$.customer":"ada",
+				"^.^.items.$length":"1",
+				"name":"a"
+			}
+		}`,
+		`{
+			"$length":"1"
+		}`,
+	)
+	testAssert(t,
+		map[string]interface{}{
+			"customer": "ada",
+			"order":    map[string]interface{}{"name": "a"},
+		},
+		`{
+			"order":{
+				"$.customer":"bob"
+			}
+		}`,
+		`{
+			"$length":"0"
+		}`,
+		OptionFail,
+	)
+}
+
+type testNullable struct {
+	Name  *string
+	Tags  []string
+	Extra interface{}
+}
+
+// go test -run TestFilterNullAndExists -v ./
+func TestFilterNullAndExists(t *testing.T) {
+	v := map[string]interface{}{
+		"a": nil,
+		"b": 1,
+		"c": &testNullable{},
+	}
+	for _, assert := range []string{
+		`{"a":null}`,
+		`{"a":{"$null":true,"$exists":true}}`,
+		`{"b":{"$null":false,"$exists":true}}`,
+		`{"x":{"$exists":false}}`,
+		`{"c.Name":null,"c.Extra":null,"c.Tags":{"$null":false}}`,
+		`{"c.Missing":{"$exists":false}}`,
+		`{"a.$type":"null"}`,
+		`{"c.Name.^.Tags.$length":"0"}`,
+	} {
+		Check(v, assert).VerifyT(t)
+	}
+	for assert, expect := range map[string]string{
+		`{"x":null}`:                 `expect x to be null, actual: <missing>`,
+		`{"b":null}`:                 `expect b to be null, actual: "1"`,
+		`{"a":{"$exists":false}}`:    `expect a.$exists to be "not exists", actual: null`,
+		`{"a":{"$null":false}}`:      `expect a.$null to be "not null", actual: null`,
+		`{"a":"null"}`:               `expect a to be "null", actual: null`,
+		`{"a":{"$null":"yes"}}`:      `bad syntax at a.$null: $null expects true or false, found "yes"`,
+		`{"x":{"$exists":true}}`:     `expect x to exist, actual: <missing>`,
+		`{"x":"1"}`:                  `expect x to be "1", actual: <missing>`,
+		`{"c":{"Name":{"$gt":"1"}}}`: `expect c.Name.$gt to be "1", actual: null`,
+	} {
+		s := Check(v, assert).String()
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", assert, `s`, expect, s)
+		}
+	}
+
+	// nil children are kept as Null
+	res, err := Query(v, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nulls := 0
+	for _, obj := range res {
+		if _, ok := obj.(*Null); ok {
+			nulls++
+		}
+	}
+	if len(res) != 3 || nulls != 1 {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[null 1 {...}]", res)
+	}
+	// null is not present in conditions
+	res, err = Query(v, "c{Name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[]", res)
+	}
+	Check(nil, `null`).VerifyT(t)
+}
@@ -2,7 +2,10 @@ package objpath_test
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/xhd2015/go-objpath"
 )
 
 // for unmarshal, must reverse the process, e.g.:
@@ -87,3 +90,96 @@ func TestJSON(t *testing.T) {
 		t.Fatalf("expect %s = %+v, actual:%+v", `dataJSON`, expectdataJSON, dataJSON)
 	}
 }
+
+func newTestRoot() *root {
+	i := int64(20)
+	return &root{
+		int:     10,
+		int64:   &i,
+		structA: structA{A0: 200, A1: "201", A2: "202", A3: "203", A4: "204", A5: "205"},
+		structB: &structB{B0: "300", A4: "304", A5: "306"},
+		structC: structC{C0: "400"},
+		structD: structD{D0: "500"},
+		A0:      100,
+		A1:      "101",
+		A2:      "102",
+	}
+}
+
+func childKeys(obj objpath.Object) string {
+	var keys []string
+	obj.(objpath.Composite).RangeChildren(func(key string, child objpath.Object) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return strings.Join(keys, ",")
+}
+
+// go test -run TestJSONFieldNames -v ./
+func TestJSONFieldNames(t *testing.T) {
+	obj := objpath.NewObjectWithOptions(newTestRoot(), &objpath.Options{FieldNames: objpath.FieldNamesJSON})
+
+	// same keys in the same order as json.Marshal
+	keys := childKeys(obj)
+	expectKeys := "sub_a1,A5,B0,b_a5,C0,A0,A1,A2"
+	if keys != expectKeys {
+		t.Fatalf("expect %s = %+v, actual:%+v", `keys`, expectKeys, keys)
+	}
+	objpath.AssertT(t, obj.Value(), `{"A1":"101"}`)
+
+	res, err := objpath.QueryObject(obj, "[sub_a1,b_a5,A5]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals := make([]string, 0, len(res))
+	for _, r := range res {
+		vals = append(vals, r.(objpath.Primitive).StrValue())
+	}
+	if strings.Join(vals, ",") != "201,306,205" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `vals`, "201,306,205", vals)
+	}
+}
+
+// go test -run TestGoFieldNames -v ./
+func TestGoFieldNames(t *testing.T) {
+	obj := objpath.NewObject(newTestRoot())
+
+	// flattened in declaration order, the last one
+	// wins at the position of the first one
+	keys := childKeys(obj)
+	expectKeys := "A0,A1,A2,A3,A4,A5,B0,C0,D0"
+	if keys != expectKeys {
+		t.Fatalf("expect %s = %+v, actual:%+v", `keys`, expectKeys, keys)
+	}
+	objpath.AssertT(t, newTestRoot(), `{"A0":"100","A3":"203","A4":"304","A5":"306","D0":"500"}`)
+
+	// through nil embedded pointer, the earlier one is kept
+	ro := newTestRoot()
+	ro.structB = nil
+	objpath.AssertT(t, ro, `{"A4":"204","A5":"205"}`)
+}
+
+// go test -run TestCaseInsensitiveFieldNames -v ./
+func TestCaseInsensitiveFieldNames(t *testing.T) {
+	opts := &objpath.Options{FieldNames: objpath.FieldNamesJSON, CaseInsensitive: true}
+	obj := objpath.NewObjectWithOptions(map[string]interface{}{"r": newTestRoot()}, opts)
+
+	for path, expect := range map[string]string{
+		"r.SUB_A1": "201",
+		"r.b_A5":   "306",
+		"r.a0":     "100",
+		"r.A0":     "100",
+	} {
+		res, err := objpath.QueryObject(obj, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].(objpath.Primitive).StrValue() != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `res`, expect, res)
+		}
+	}
+	res, err := objpath.QueryObject(objpath.NewObject(newTestRoot()), "a0")
+	if err != nil || len(res) != 0 {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[]", res)
+	}
+}
@@ -0,0 +1,215 @@
+package objpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// NewJSONObject creates an Object from JSON text without
+// decoding it into Go values first: objects are JSONMap in
+// the original key order, arrays are JSONList, numbers
+// are json.Number and null is Null. Objects and arrays are
+// decoded only when their children are accessed.
+func NewJSONObject(data []byte) (Object, error) {
+	// validates the whole text once, so that
+	// decoding sub-trees later cannot fail
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return newJSONObject(raw), nil
+}
+
+// QueryJSON is like Query, but against JSON text
+func QueryJSON(data []byte, path string) ([]Object, error) {
+	obj, err := NewJSONObject(data)
+	if err != nil {
+		return nil, err
+	}
+	return QueryObject(obj, path)
+}
+
+// CheckJSON is like Check, but against JSON text,
+// e.g. a HTTP response body
+func CheckJSON(data []byte, asserts string) Result {
+	obj, err := NewJSONObject(data)
+	if err != nil {
+		return Result{{BadSyntax: fmt.Sprintf("parsing json: %v", err)}}
+	}
+	return CheckObject(obj, asserts)
+}
+
+// newJSONObject creates the Object of valid JSON value raw
+func newJSONObject(raw json.RawMessage) Object {
+	if len(raw) == 0 {
+		return NewNull(nil)
+	}
+	switch raw[0] {
+	case '{':
+		return &JSONMap{raw: raw}
+	case '[':
+		return &JSONList{raw: raw}
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return NewNull(nil)
+		}
+		return NewPrimitve(s, s)
+	case 't', 'f':
+		b := raw[0] == 't'
+		return NewPrimitve(b, strconv.FormatBool(b))
+	case 'n':
+		return NewNull(nil)
+	}
+	n := json.Number(raw)
+	return NewPrimitve(n, n.String())
+}
+
+func newJSONChild(parent Object, key string, raw json.RawMessage) Object {
+	child := newJSONObject(raw)
+	if l, ok := child.(locatable); ok {
+		l.setLocation(parent, key)
+	}
+	return child
+}
+
+// JSONMap is a JSON object from NewJSONObject
+type JSONMap struct {
+	raw  json.RawMessage
+	m    *SortedMap // map[string]Object
+	once sync.Once
+	loc
+}
+
+var _ Composite = ((*JSONMap)(nil))
+
+// Value implements Object, it is the JSON text
+func (c *JSONMap) Value() interface{} {
+	return c.raw
+}
+
+// Method implements Object, JSONMap has no methods
+func (c *JSONMap) Method(name string) (method interface{}, ok bool) {
+	return nil, false
+}
+
+// ChildrenLen implements Composite
+func (c *JSONMap) ChildrenLen() int {
+	return c.getChildren().Len()
+}
+
+// GetChild implements Composite
+func (c *JSONMap) GetChild(key string) (child Object, ok bool) {
+	v, ok := c.getChildren().GetOK(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(Object), true
+}
+
+// RangeChildren implements Composite, in the order of the JSON text
+func (c *JSONMap) RangeChildren(fn func(key string, child Object) bool) {
+	c.getChildren().Range(func(key string, val interface{}) bool {
+		return fn(key, val.(Object))
+	})
+}
+
+func (c *JSONMap) String() string {
+	return string(c.raw)
+}
+
+func (c *JSONMap) getChildren() *SortedMap {
+	c.once.Do(func() {
+		c.m = NewSortedMap(0)
+		dec := json.NewDecoder(bytes.NewReader(c.raw))
+		if _, err := dec.Token(); err != nil {
+			return
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return
+			}
+			key, _ := tok.(string)
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return
+			}
+			// the last one wins, like json.Unmarshal
+			c.m.Set(key, newJSONChild(c, key, raw))
+		}
+	})
+	return c.m
+}
+
+// JSONList is a JSON array from NewJSONObject
+type JSONList struct {
+	raw  json.RawMessage
+	list []Object
+	once sync.Once
+	loc
+}
+
+var _ Composite = ((*JSONList)(nil))
+
+// Value implements Object, it is the JSON text
+func (c *JSONList) Value() interface{} {
+	return c.raw
+}
+
+// Method implements Object, JSONList has no methods
+func (c *JSONList) Method(name string) (method interface{}, ok bool) {
+	return nil, false
+}
+
+// ChildrenLen implements Composite
+func (c *JSONList) ChildrenLen() int {
+	return len(c.getChildren())
+}
+
+// GetChild implements Composite
+func (c *JSONList) GetChild(key string) (child Object, ok bool) {
+	i, err := strconv.ParseInt(key, 10, 64)
+	if err != nil || i < 0 {
+		return nil, false
+	}
+	children := c.getChildren()
+	if i >= int64(len(children)) {
+		return nil, false
+	}
+	return children[i], true
+}
+
+// RangeChildren implements Composite
+func (c *JSONList) RangeChildren(fn func(key string, child Object) bool) {
+	for i, child := range c.getChildren() {
+		if !fn(strconv.Itoa(i), child) {
+			return
+		}
+	}
+}
+
+func (c *JSONList) String() string {
+	return string(c.raw)
+}
+
+func (c *JSONList) getChildren() []Object {
+	c.once.Do(func() {
+		c.list = []Object{}
+		dec := json.NewDecoder(bytes.NewReader(c.raw))
+		if _, err := dec.Token(); err != nil {
+			return
+		}
+		for dec.More() {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return
+			}
+			c.list = append(c.list, newJSONChild(c, strconv.Itoa(len(c.list)), raw))
+		}
+	})
+	return c.list
+}
@@ -0,0 +1,117 @@
+package objpath
+
+import (
+	"strings"
+	"testing"
+)
+
+const testJSONBody = `{
+	"id": 12345678901234567891,
+	"name": "order",
+	"price": 10.50,
+	"paid": true,
+	"note": null,
+	"items": [
+		{"sku": "b", "qty": 2},
+		{"sku": "a", "qty": 1, "tags": []}
+	],
+	"meta": {"z": 1, "a": 2, "m": {"deep": [1, 2, 3]}}
+}`
+
+// go test -run TestQueryJSON -v ./
+func TestQueryJSON(t *testing.T) {
+	for path, expect := range map[string]string{
+		"id":                      "[12345678901234567891]",
+		"price":                   "[10.50]",
+		"paid":                    "[true]",
+		"note":                    "[null]",
+		"items.*{qty>1}.sku":      "[b]",
+		"items[-1].sku":           "[a]",
+		"meta.*":                  "[1 2 {\"deep\": [1, 2, 3]}]",
+		"meta.$keys.*":            "[z a m]",
+		"meta.m.deep.$length":     "[3]",
+		"items.1.tags.$type":      "[list]",
+		"meta.$type":              "[map]",
+		"id.$type":                "[number]",
+		"$..deep[1]":              "[2]",
+		"meta.$values.*.$type":    "[number number map]",
+		"items.$values.*.sku":     "[b a]",
+		"meta.$values[-1].deep.1": "[2]",
+		"meta.$values.$type":      "[list]",
+		"missing":                 "[]",
+	} {
+		res, err := QueryJSON([]byte(testJSONBody), path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := strValues(res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `res`, expect, s)
+		}
+	}
+
+	if _, err := QueryJSON([]byte(`{"a":`), "a"); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+// go test -run TestCheckJSON -v ./
+func TestCheckJSON(t *testing.T) {
+	CheckJSON([]byte(testJSONBody), `{
+		"id":{"$gt":"12345678901234567890"},
+		"price":10.5,
+		"paid":true,
+		"note":null,
+		"discount":{"$exists":false},
+		"items.*{sku=a}.qty":1,
+		"meta.m.deep.$last":3
+	}`).VerifyT(t)
+
+	s := CheckJSON([]byte(testJSONBody), `{"items.*":{"qty":{"$gt":2}}}`).String()
+	expect := "expect items.0.qty.$gt to be \"2\", actual: \"2\"\nexpect items.1.qty.$gt to be \"2\", actual: \"1\""
+	if s != expect {
+		t.Fatalf("expect %s = %+v, actual:%+v", `s`, expect, s)
+	}
+	s = CheckJSON([]byte(`{"a":`), `{"a":1}`).String()
+	if !strings.HasPrefix(s, "bad syntax at : parsing json:") {
+		t.Fatalf("expect %s = %+v, actual:%+v", `s`, "bad syntax", s)
+	}
+}
+
+// go test -run TestJSONObjectLazy -v ./
+func TestJSONObjectLazy(t *testing.T) {
+	obj, err := NewJSONObject([]byte(testJSONBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := QueryObject(obj, "meta.z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(res); s != "[1]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[1]", s)
+	}
+	root := obj.(*JSONMap)
+	items, _ := root.GetChild("items")
+	if items.(*JSONList).list != nil {
+		t.Fatalf("expect items not decoded")
+	}
+	meta, _ := root.GetChild("meta")
+	m, _ := meta.(*JSONMap).GetChild("m")
+	if m.(*JSONMap).m != nil {
+		t.Fatalf("expect meta.m not decoded")
+	}
+
+	// duplicate keys: the last one wins at the first position
+	obj, err = NewJSONObject([]byte(`{"a":1,"b":2,"a":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = QueryObject(obj, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(res); s != "[3 2]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[3 2]", s)
+	}
+}
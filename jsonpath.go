@@ -0,0 +1,1227 @@
+package objpath
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// JSONPath is a compiled RFC 9535 JSONPath expression, like
+// $.store.book[?@.price < 10].title. It can be used
+// concurrently by multiple goroutines.
+//
+// JSON null is represented by Null in results.
+type JSONPath struct {
+	src   string
+	query *jpQuery
+}
+
+// CompileJSONPath parses a JSONPath expression, on syntax
+// error a *PathSyntaxError is returned
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	p := &jpParser{src: expr}
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	if query.relative {
+		return nil, p.errorf(0, "expecting '$', found '@'")
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf(p.pos, "unexpected %q", p.src[p.pos:])
+	}
+	return &JSONPath{src: expr, query: query}, nil
+}
+
+// MustCompileJSONPath is like CompileJSONPath but panics on error,
+// it is intended for initializing global paths.
+func MustCompileJSONPath(expr string) *JSONPath {
+	p, err := CompileJSONPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// QueryJSONPath compiles expr as JSONPath and queries it against v
+func QueryJSONPath(v interface{}, expr string) ([]Object, error) {
+	p, err := CompileJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(v), nil
+}
+
+// String returns the source of the path
+func (c *JSONPath) String() string {
+	return c.src
+}
+
+func (c *JSONPath) Query(v interface{}) []Object {
+	root := NewObject(v)
+	if root == nil {
+		root = NewNull(v)
+	}
+	return c.QueryObject(root)
+}
+
+func (c *JSONPath) QueryObject(root Object) []Object {
+	return c.query.eval(root, root)
+}
+
+// jpQuery is $ or @ followed by segments
+type jpQuery struct {
+	relative bool // starts with @
+	segments []*jpSegment
+}
+
+// jpSegment is .name, [selectors] or ..[selectors]
+type jpSegment struct {
+	descendant bool
+	selectors  []jpSelector
+}
+
+// jpSelector appends selected children of node to res
+type jpSelector interface {
+	selectNodes(root Object, node Object, res []Object) []Object
+}
+
+type jpName string
+type jpWildcard struct{}
+type jpIndex int
+type jpSlice struct {
+	slice listSlice
+}
+type jpFilter struct {
+	expr jpLogical
+}
+
+// jpLogical is the expression of a filter selector
+type jpLogical interface {
+	test(root Object, cur Object) bool
+}
+
+type jpOr []jpLogical
+type jpAnd []jpLogical
+type jpNot struct {
+	expr jpLogical
+}
+
+// jpExist holds when query selects any node
+type jpExist struct {
+	query *jpQuery
+}
+
+// jpFuncTest holds when the function returns true,
+// or any node for functions returning nodes
+type jpFuncTest struct {
+	fn *jpFunc
+}
+
+type jpComparison struct {
+	op    string
+	left  jpComparable
+	right jpComparable
+}
+
+// jpComparable is a literal, a singular query or
+// a function returning value
+type jpComparable interface {
+	value(root Object, cur Object) jpValue
+}
+
+// jpValue is a JSON value, or nothing for empty
+// results, e.g. a singular query selecting no node
+type jpValue struct {
+	obj     Object
+	nothing bool
+}
+
+type jpLiteral jpValue
+
+// jpSingular is a query selecting at most one node
+type jpSingular struct {
+	query *jpQuery
+}
+
+type jpType int
+
+const (
+	jpValueType jpType = iota
+	jpLogicalType
+	jpNodesType
+)
+
+// jpFuncDef declares a function extension, args are
+// jpValue, bool or []Object by params, so is the result
+type jpFuncDef struct {
+	params []jpType
+	result jpType
+	call   func(args []interface{}) interface{}
+}
+
+type jpFunc struct {
+	name string
+	def  *jpFuncDef
+	args []jpArg
+}
+
+// jpArg is evaluated by the param type
+type jpArg interface{}
+
+var jpFuncs = map[string]*jpFuncDef{
+	"length": {params: []jpType{jpValueType}, result: jpValueType, call: jpLength},
+	"count":  {params: []jpType{jpNodesType}, result: jpValueType, call: jpCount},
+	"match":  {params: []jpType{jpValueType, jpValueType}, result: jpLogicalType, call: jpMatch},
+	"search": {params: []jpType{jpValueType, jpValueType}, result: jpLogicalType, call: jpSearch},
+	"value":  {params: []jpType{jpNodesType}, result: jpValueType, call: jpValueOf},
+}
+
+func (c *jpQuery) eval(root Object, cur Object) []Object {
+	start := root
+	if c.relative {
+		start = cur
+	}
+	nodes := []Object{start}
+	for _, seg := range c.segments {
+		var res []Object
+		for _, node := range nodes {
+			if !seg.descendant {
+				for _, sel := range seg.selectors {
+					res = sel.selectNodes(root, node, res)
+				}
+				continue
+			}
+			jpDescend(node, func(d Object) {
+				for _, sel := range seg.selectors {
+					res = sel.selectNodes(root, d, res)
+				}
+			})
+		}
+		nodes = res
+		if len(nodes) == 0 {
+			return nil
+		}
+	}
+	return nodes
+}
+
+// singular tells whether the query selects at most one
+// node, i.e. only names and indices are used
+func (c *jpQuery) singular() bool {
+	for _, seg := range c.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		switch seg.selectors[0].(type) {
+		case jpName, jpIndex:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// jpDescend visits node and all its descendants, parents
+// first, Refs are selected from their parents but not visited
+func jpDescend(node Object, visit func(d Object)) {
+	visit(node)
+	comp, ok := node.(Composite)
+	if !ok {
+		return
+	}
+	comp.RangeChildren(func(key string, child Object) bool {
+		if !IsRef(child) {
+			jpDescend(child, visit)
+		}
+		return true
+	})
+}
+
+func (c jpName) selectNodes(root Object, node Object, res []Object) []Object {
+	comp, ok := node.(Composite)
+	if !ok || isJSONArray(node) {
+		return res
+	}
+	child, ok := comp.GetChild(string(c))
+	if !ok {
+		return res
+	}
+	return append(res, child)
+}
+
+func (c jpWildcard) selectNodes(root Object, node Object, res []Object) []Object {
+	comp, ok := node.(Composite)
+	if !ok {
+		return res
+	}
+	comp.RangeChildren(func(key string, child Object) bool {
+		res = append(res, child)
+		return true
+	})
+	return res
+}
+
+func (c jpIndex) selectNodes(root Object, node Object, res []Object) []Object {
+	list, ok := asList(node)
+	if !ok {
+		return res
+	}
+	children := list.getChildren()
+	i := int(c)
+	if i < 0 {
+		i += len(children)
+	}
+	if i < 0 || i >= len(children) {
+		return res
+	}
+	return append(res, children[i])
+}
+
+func (c *jpSlice) selectNodes(root Object, node Object, res []Object) []Object {
+	list, ok := asList(node)
+	if !ok || c.slice.step == 0 {
+		// step 0 selects nothing
+		return res
+	}
+	children := list.getChildren()
+	start, end, step := c.slice.bounds(len(children))
+	if step > 0 {
+		for i := start; i < end; i += step {
+			res = append(res, children[i])
+		}
+	} else {
+		for i := start; i > end; i += step {
+			res = append(res, children[i])
+		}
+	}
+	return res
+}
+
+func (c *jpFilter) selectNodes(root Object, node Object, res []Object) []Object {
+	comp, ok := node.(Composite)
+	if !ok {
+		return res
+	}
+	comp.RangeChildren(func(key string, child Object) bool {
+		if c.expr.test(root, child) {
+			res = append(res, child)
+		}
+		return true
+	})
+	return res
+}
+
+func (c jpOr) test(root Object, cur Object) bool {
+	for _, e := range c {
+		if e.test(root, cur) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c jpAnd) test(root Object, cur Object) bool {
+	for _, e := range c {
+		if !e.test(root, cur) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *jpNot) test(root Object, cur Object) bool {
+	return !c.expr.test(root, cur)
+}
+
+func (c *jpExist) test(root Object, cur Object) bool {
+	return len(c.query.eval(root, cur)) > 0
+}
+
+func (c *jpFuncTest) test(root Object, cur Object) bool {
+	switch res := c.fn.call(root, cur).(type) {
+	case bool:
+		return res
+	case []Object:
+		return len(res) > 0
+	}
+	return false
+}
+
+func (c *jpComparison) test(root Object, cur Object) bool {
+	a := c.left.value(root, cur)
+	b := c.right.value(root, cur)
+	switch c.op {
+	case "==":
+		return jpEqual(a, b)
+	case "!=":
+		return !jpEqual(a, b)
+	case "<":
+		return jpLess(a, b)
+	case "<=":
+		return jpLess(a, b) || jpEqual(a, b)
+	case ">":
+		return jpLess(b, a)
+	case ">=":
+		return jpLess(b, a) || jpEqual(a, b)
+	}
+	return false
+}
+
+func (c jpLiteral) value(root Object, cur Object) jpValue {
+	return jpValue(c)
+}
+
+func (c *jpSingular) value(root Object, cur Object) jpValue {
+	nodes := c.query.eval(root, cur)
+	if len(nodes) != 1 {
+		return jpValue{nothing: true}
+	}
+	return jpValue{obj: nodes[0]}
+}
+
+func (c *jpFunc) value(root Object, cur Object) jpValue {
+	v, _ := c.call(root, cur).(jpValue)
+	return v
+}
+
+func (c *jpFunc) call(root Object, cur Object) interface{} {
+	args := make([]interface{}, len(c.args))
+	for i, arg := range c.args {
+		switch c.def.params[i] {
+		case jpValueType:
+			args[i] = arg.(jpComparable).value(root, cur)
+		case jpLogicalType:
+			args[i] = arg.(jpLogical).test(root, cur)
+		case jpNodesType:
+			switch arg := arg.(type) {
+			case *jpQuery:
+				args[i] = arg.eval(root, cur)
+			case *jpFunc:
+				args[i], _ = arg.call(root, cur).([]Object)
+			}
+		}
+	}
+	return c.def.call(args)
+}
+
+// jsonKind is one of null,bool,number,string,array,object
+func jsonKind(obj Object) string {
+	switch t := typeOf(obj); t {
+	case "list":
+		return "array"
+	case "map", "struct":
+		return "object"
+	default:
+		return t
+	}
+}
+
+func isJSONArray(obj Object) bool {
+	return jsonKind(obj) == "array"
+}
+
+func jpNumber(obj Object) float64 {
+	f, _ := strconv.ParseFloat(obj.(Primitive).StrValue(), 64)
+	return f
+}
+
+func jpEqual(a jpValue, b jpValue) bool {
+	if a.nothing || b.nothing {
+		return a.nothing && b.nothing
+	}
+	return jsonEqual(a.obj, b.obj)
+}
+
+// jsonEqual compares a and b as JSON values,
+// arrays and objects are compared deeply
+func jsonEqual(a Object, b Object) bool {
+	kind := jsonKind(a)
+	if kind != jsonKind(b) {
+		return false
+	}
+	switch kind {
+	case "null":
+		return true
+	case "number":
+		return jpNumber(a) == jpNumber(b)
+	case "string", "bool":
+		return a.(Primitive).StrValue() == b.(Primitive).StrValue()
+	case "array":
+		as := jsonChildren(a.(Composite))
+		bs := jsonChildren(b.(Composite))
+		if len(as) != len(bs) {
+			return false
+		}
+		for i := range as {
+			if !jsonEqual(as[i], bs[i]) {
+				return false
+			}
+		}
+		return true
+	case "object":
+		ac, bc := a.(Composite), b.(Composite)
+		if ac.ChildrenLen() != bc.ChildrenLen() {
+			return false
+		}
+		equal := true
+		ac.RangeChildren(func(key string, child Object) bool {
+			other, ok := bc.GetChild(key)
+			equal = ok && jsonEqual(child, other)
+			return equal
+		})
+		return equal
+	}
+	return false
+}
+
+func jsonChildren(comp Composite) []Object {
+	children := make([]Object, 0, comp.ChildrenLen())
+	comp.RangeChildren(func(key string, child Object) bool {
+		children = append(children, child)
+		return true
+	})
+	return children
+}
+
+// jpLess only orders numbers and strings
+func jpLess(a jpValue, b jpValue) bool {
+	if a.nothing || b.nothing {
+		return false
+	}
+	kind := jsonKind(a.obj)
+	if kind != jsonKind(b.obj) {
+		return false
+	}
+	switch kind {
+	case "number":
+		return jpNumber(a.obj) < jpNumber(b.obj)
+	case "string":
+		// byte order of UTF-8 is the order of code points
+		return a.obj.(Primitive).StrValue() < b.obj.(Primitive).StrValue()
+	}
+	return false
+}
+
+// length(value): length of string, array or object
+func jpLength(args []interface{}) interface{} {
+	v := args[0].(jpValue)
+	if v.nothing {
+		return v
+	}
+	switch jsonKind(v.obj) {
+	case "string":
+		return jpValue{obj: newIntPrimitive(utf8.RuneCountInString(v.obj.(Primitive).StrValue()))}
+	case "array", "object":
+		return jpValue{obj: newIntPrimitive(v.obj.(Composite).ChildrenLen())}
+	}
+	return jpValue{nothing: true}
+}
+
+// count(nodes): number of nodes
+func jpCount(args []interface{}) interface{} {
+	return jpValue{obj: newIntPrimitive(len(args[0].([]Object)))}
+}
+
+// value(nodes): value of the only node
+func jpValueOf(args []interface{}) interface{} {
+	nodes := args[0].([]Object)
+	if len(nodes) != 1 {
+		return jpValue{nothing: true}
+	}
+	return jpValue{obj: nodes[0]}
+}
+
+// match(string, regex): whether the whole string matches
+func jpMatch(args []interface{}) interface{} {
+	return jpRegexpTest(args, true)
+}
+
+// search(string, regex): whether a substring matches
+func jpSearch(args []interface{}) interface{} {
+	return jpRegexpTest(args, false)
+}
+
+func jpRegexpTest(args []interface{}, full bool) bool {
+	s, b := args[0].(jpValue), args[1].(jpValue)
+	if s.nothing || b.nothing || jsonKind(s.obj) != "string" || jsonKind(b.obj) != "string" {
+		return false
+	}
+	re := jpRegexp(b.obj.(Primitive).StrValue(), full)
+	if re == nil {
+		// not a valid regex
+		return false
+	}
+	return re.MatchString(s.obj.(Primitive).StrValue())
+}
+
+// jpRegexps caches regexes given as literals,
+// which are compiled when the path is parsed
+var jpRegexps sync.Map
+
+type jpRegexpKey struct {
+	pattern string
+	full    bool
+}
+
+func jpRegexp(pattern string, full bool) *regexp.Regexp {
+	key := jpRegexpKey{pattern: pattern, full: full}
+	if re, ok := jpRegexps.Load(key); ok {
+		return re.(*regexp.Regexp)
+	}
+	return compileIRegexp(pattern, full)
+}
+
+// compileIRegexp compiles RFC 9485 I-Regexp, which is
+// mostly a subset of RE2 except that '.' does not
+// match \n and \r, nil is returned if invalid
+func compileIRegexp(pattern string, full bool) *regexp.Regexp {
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case ch == '\\' && i+1 < len(pattern):
+			b.WriteByte(ch)
+			i++
+			b.WriteByte(pattern[i])
+			continue
+		case ch == '[':
+			inClass = true
+		case ch == ']':
+			inClass = false
+		case ch == '.' && !inClass:
+			b.WriteString(`[^\n\r]`)
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	expr := b.String()
+	if full {
+		expr = `\A(?:` + expr + `)\z`
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// jpParser parses JSONPath by recursive descent,
+// spaces are only allowed where RFC 9535 allows
+type jpParser struct {
+	src string
+	pos int
+}
+
+func (c *jpParser) errorf(pos int, format string, args ...interface{}) error {
+	return newPathSyntaxError(c.src, pos, format, args...)
+}
+
+func (c *jpParser) skipSpace() {
+	for c.pos < len(c.src) && isSpace(c.src[c.pos]) {
+		c.pos++
+	}
+}
+
+func (c *jpParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(c.src[c.pos:], s)
+}
+
+// found describes what is at pos for errors
+func (c *jpParser) found() string {
+	if c.pos >= len(c.src) {
+		return "end of path"
+	}
+	r, _ := utf8.DecodeRuneInString(c.src[c.pos:])
+	return strconv.QuoteRune(r)
+}
+
+func (c *jpParser) expect(s string) error {
+	if !c.hasPrefix(s) {
+		return c.errorf(c.pos, "expecting '%s', found %s", s, c.found())
+	}
+	c.pos += len(s)
+	return nil
+}
+
+// parseQuery parses $ or @ followed by segments
+func (c *jpParser) parseQuery() (*jpQuery, error) {
+	query := &jpQuery{}
+	switch {
+	case c.hasPrefix("$"):
+	case c.hasPrefix("@"):
+		query.relative = true
+	default:
+		return nil, c.errorf(c.pos, "expecting '$', found %s", c.found())
+	}
+	c.pos++
+	for {
+		save := c.pos
+		c.skipSpace()
+		var seg *jpSegment
+		var err error
+		switch {
+		case c.hasPrefix(".."):
+			c.pos += 2
+			if c.hasPrefix("[") {
+				seg, err = c.parseBracketed()
+			} else {
+				seg, err = c.parseShorthand()
+			}
+			if seg != nil {
+				seg.descendant = true
+			}
+		case c.hasPrefix("."):
+			c.pos++
+			seg, err = c.parseShorthand()
+		case c.hasPrefix("["):
+			seg, err = c.parseBracketed()
+		default:
+			c.pos = save
+			return query, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		query.segments = append(query.segments, seg)
+	}
+}
+
+// parseShorthand parses * or a member name after . or ..
+func (c *jpParser) parseShorthand() (*jpSegment, error) {
+	if c.hasPrefix("*") {
+		c.pos++
+		return &jpSegment{selectors: []jpSelector{jpWildcard{}}}, nil
+	}
+	start := c.pos
+	for c.pos < len(c.src) {
+		r, size := utf8.DecodeRuneInString(c.src[c.pos:])
+		if !isJPNameChar(r, c.pos == start) {
+			break
+		}
+		c.pos += size
+	}
+	if c.pos == start {
+		return nil, c.errorf(c.pos, "expecting member name or '*', found %s", c.found())
+	}
+	return &jpSegment{selectors: []jpSelector{jpName(c.src[start:c.pos])}}, nil
+}
+
+func isJPNameChar(r rune, first bool) bool {
+	switch {
+	case r == utf8.RuneError:
+		return false
+	case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= 0x80:
+		return true
+	case r >= '0' && r <= '9':
+		return !first
+	}
+	return false
+}
+
+// parseBracketed parses [selector, ...]
+func (c *jpParser) parseBracketed() (*jpSegment, error) {
+	c.pos++ // [
+	seg := &jpSegment{}
+	for {
+		c.skipSpace()
+		sel, err := c.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		seg.selectors = append(seg.selectors, sel)
+		c.skipSpace()
+		if c.hasPrefix(",") {
+			c.pos++
+			continue
+		}
+		if err := c.expect("]"); err != nil {
+			return nil, err
+		}
+		return seg, nil
+	}
+}
+
+func (c *jpParser) parseSelector() (jpSelector, error) {
+	switch {
+	case c.hasPrefix("'"), c.hasPrefix(`"`):
+		s, err := c.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return jpName(s), nil
+	case c.hasPrefix("*"):
+		c.pos++
+		return jpWildcard{}, nil
+	case c.hasPrefix("?"):
+		c.pos++
+		c.skipSpace()
+		expr, err := c.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return &jpFilter{expr: expr}, nil
+	}
+	// index or slice: start:end:step
+	var parts [3]*int
+	n := 0
+	for {
+		if c.pos < len(c.src) && (c.src[c.pos] == '-' || isDigit(c.src[c.pos])) {
+			i, err := c.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			parts[n] = &i
+			c.skipSpace()
+		}
+		if n == 2 || !c.hasPrefix(":") {
+			break
+		}
+		c.pos++
+		c.skipSpace()
+		n++
+	}
+	if n == 0 {
+		if parts[0] == nil {
+			return nil, c.errorf(c.pos, "expecting selector, found %s", c.found())
+		}
+		return jpIndex(*parts[0]), nil
+	}
+	sel := &jpSlice{slice: listSlice{start: parts[0], end: parts[1], step: 1}}
+	if parts[2] != nil {
+		sel.slice.step = *parts[2]
+	}
+	return sel, nil
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// maxJSONInt is the max exact integer in I-JSON, 2^53-1
+const maxJSONInt = 1<<53 - 1
+
+// parseInt parses 0 or -?[1-9][0-9]*
+func (c *jpParser) parseInt() (int, error) {
+	start := c.pos
+	if c.hasPrefix("-") {
+		c.pos++
+	}
+	digits := c.pos
+	for c.pos < len(c.src) && isDigit(c.src[c.pos]) {
+		c.pos++
+	}
+	text := c.src[start:c.pos]
+	if c.pos == digits || (c.src[digits] == '0' && (c.pos-digits > 1 || digits > start)) {
+		return 0, c.errorf(start, "invalid integer %q", text)
+	}
+	i, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || i > maxJSONInt || i < -maxJSONInt {
+		return 0, c.errorf(start, "integer out of range: %s", text)
+	}
+	return int(i), nil
+}
+
+// parseString parses a string quoted by ' or ",
+// escapes are the same as JSON, plus \' in ”
+func (c *jpParser) parseString() (string, error) {
+	start := c.pos
+	quote := c.src[c.pos]
+	c.pos++
+	var b strings.Builder
+	for c.pos < len(c.src) {
+		ch := c.src[c.pos]
+		switch {
+		case ch == quote:
+			c.pos++
+			return b.String(), nil
+		case ch < 0x20:
+			return "", c.errorf(c.pos, "control char in string")
+		case ch != '\\':
+			b.WriteByte(ch)
+			c.pos++
+			continue
+		}
+		// escape
+		escPos := c.pos
+		c.pos++
+		if c.pos >= len(c.src) {
+			break
+		}
+		esc := c.src[c.pos]
+		c.pos++
+		switch esc {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\':
+			b.WriteByte(esc)
+		case '\'', '"':
+			if esc != quote {
+				return "", c.errorf(escPos, "invalid escape \\%c", esc)
+			}
+			b.WriteByte(esc)
+		case 'u':
+			r, err := c.parseUnicodeEscape(escPos)
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+		default:
+			return "", c.errorf(escPos, "invalid escape \\%c", esc)
+		}
+	}
+	return "", c.errorf(start, "found %c, but missing closing %c", quote, quote)
+}
+
+// parseUnicodeEscape parses XXXX after \u, surrogate
+// pairs must be given as \uXXXX\uXXXX
+func (c *jpParser) parseUnicodeEscape(escPos int) (rune, error) {
+	hex := func() (rune, bool) {
+		if c.pos+4 > len(c.src) {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(c.src[c.pos:c.pos+4], 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		c.pos += 4
+		return rune(n), true
+	}
+	r, ok := hex()
+	if !ok {
+		return 0, c.errorf(escPos, "invalid unicode escape")
+	}
+	switch {
+	case utf16.IsSurrogate(r) && r < 0xDC00:
+		// high surrogate, expecting the low one
+		if !c.hasPrefix(`\u`) {
+			return 0, c.errorf(escPos, "missing low surrogate")
+		}
+		c.pos += 2
+		low, ok := hex()
+		if !ok || low < 0xDC00 || low > 0xDFFF {
+			return 0, c.errorf(escPos, "invalid low surrogate")
+		}
+		return utf16.DecodeRune(r, low), nil
+	case utf16.IsSurrogate(r):
+		return 0, c.errorf(escPos, "unexpected low surrogate")
+	}
+	return r, nil
+}
+
+// parseOr parses and-expr || and-expr ...
+func (c *jpParser) parseOr() (jpLogical, error) {
+	var or jpOr
+	for {
+		and, err := c.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, and)
+		c.skipSpace()
+		if !c.hasPrefix("||") {
+			break
+		}
+		c.pos += 2
+		c.skipSpace()
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+// parseAnd parses basic-expr && basic-expr ...
+func (c *jpParser) parseAnd() (jpLogical, error) {
+	var and jpAnd
+	for {
+		basic, err := c.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, basic)
+		c.skipSpace()
+		if !c.hasPrefix("&&") {
+			break
+		}
+		c.pos += 2
+		c.skipSpace()
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// parseBasic parses (expr), !(expr), comparisons
+// and existence tests like @.a, !@.a, match(@.a, 'x')
+func (c *jpParser) parseBasic() (jpLogical, error) {
+	if c.hasPrefix("!") {
+		c.pos++
+		c.skipSpace()
+		start := c.pos
+		expr, err := c.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := expr.(*jpComparison); ok {
+			return nil, c.errorf(start, "comparison must be in parentheses after '!'")
+		}
+		return &jpNot{expr: expr}, nil
+	}
+	if c.hasPrefix("(") {
+		c.pos++
+		c.skipSpace()
+		expr, err := c.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		c.skipSpace()
+		if err := c.expect(")"); err != nil {
+			return nil, err
+		}
+		return &jpParen{expr: expr}, nil
+	}
+
+	start := c.pos
+	left, err := c.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	save := c.pos
+	c.skipSpace()
+	op := c.parseCompareOp()
+	if op == "" {
+		c.pos = save
+		switch left := left.(type) {
+		case *jpQuery:
+			return &jpExist{query: left}, nil
+		case *jpFunc:
+			if left.def.result == jpValueType {
+				return nil, c.errorf(start, "function %s() must be compared", left.name)
+			}
+			return &jpFuncTest{fn: left}, nil
+		}
+		return nil, c.errorf(c.pos, "expecting comparison operator, found %s", c.found())
+	}
+	leftCmp, err := c.comparable(left, start)
+	if err != nil {
+		return nil, err
+	}
+	c.skipSpace()
+	rightStart := c.pos
+	right, err := c.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	rightCmp, err := c.comparable(right, rightStart)
+	if err != nil {
+		return nil, err
+	}
+	return &jpComparison{op: op, left: leftCmp, right: rightCmp}, nil
+}
+
+// jpParen keeps (expr) apart from a bare comparison,
+// so that !(a==b) is allowed while !a==b is not
+type jpParen struct {
+	expr jpLogical
+}
+
+func (c *jpParen) test(root Object, cur Object) bool {
+	return c.expr.test(root, cur)
+}
+
+func (c *jpParser) parseCompareOp() string {
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if c.hasPrefix(op) {
+			c.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// parseOperand parses a literal, a query or a function call,
+// returning jpLiteral, *jpQuery or *jpFunc
+func (c *jpParser) parseOperand() (interface{}, error) {
+	if c.pos >= len(c.src) {
+		return nil, c.errorf(c.pos, "expecting expression, found end of path")
+	}
+	ch := c.src[c.pos]
+	switch {
+	case ch == '$' || ch == '@':
+		return c.parseQuery()
+	case ch == '\'' || ch == '"':
+		s, err := c.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return jpLiteral{obj: NewPrimitve(s, s)}, nil
+	case ch == '-' || isDigit(ch):
+		return c.parseNumber()
+	case ch >= 'a' && ch <= 'z':
+		start := c.pos
+		for c.pos < len(c.src) && (c.src[c.pos] == '_' || isDigit(c.src[c.pos]) || (c.src[c.pos] >= 'a' && c.src[c.pos] <= 'z')) {
+			c.pos++
+		}
+		name := c.src[start:c.pos]
+		if c.hasPrefix("(") {
+			return c.parseFunc(name, start)
+		}
+		switch name {
+		case "true", "false":
+			return jpLiteral{obj: NewPrimitve(name == "true", name)}, nil
+		case "null":
+			return jpLiteral{}, nil
+		}
+		c.pos = start
+	}
+	return nil, c.errorf(c.pos, "expecting expression, found %s", c.found())
+}
+
+// parseNumber parses a JSON number, -0 is allowed
+func (c *jpParser) parseNumber() (interface{}, error) {
+	start := c.pos
+	if c.hasPrefix("-") {
+		c.pos++
+	}
+	intStart := c.pos
+	for c.pos < len(c.src) && isDigit(c.src[c.pos]) {
+		c.pos++
+	}
+	if c.pos == intStart || (c.src[intStart] == '0' && c.pos-intStart > 1) {
+		return nil, c.errorf(start, "invalid number")
+	}
+	if c.hasPrefix(".") {
+		c.pos++
+		fracStart := c.pos
+		for c.pos < len(c.src) && isDigit(c.src[c.pos]) {
+			c.pos++
+		}
+		if c.pos == fracStart {
+			return nil, c.errorf(start, "invalid number")
+		}
+	}
+	if c.hasPrefix("e") || c.hasPrefix("E") {
+		c.pos++
+		if c.hasPrefix("+") || c.hasPrefix("-") {
+			c.pos++
+		}
+		expStart := c.pos
+		for c.pos < len(c.src) && isDigit(c.src[c.pos]) {
+			c.pos++
+		}
+		if c.pos == expStart {
+			return nil, c.errorf(start, "invalid number")
+		}
+	}
+	text := c.src[start:c.pos]
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, c.errorf(start, "invalid number: %s", text)
+	}
+	return jpLiteral{obj: NewPrimitve(f, text)}, nil
+}
+
+// comparable checks operand can be compared: literals,
+// singular queries and functions returning value
+func (c *jpParser) comparable(operand interface{}, pos int) (jpComparable, error) {
+	switch operand := operand.(type) {
+	case jpLiteral:
+		return operand, nil
+	case *jpQuery:
+		if !operand.singular() {
+			return nil, c.errorf(pos, "query in comparison must be singular")
+		}
+		return &jpSingular{query: operand}, nil
+	case *jpFunc:
+		if operand.def.result != jpValueType {
+			return nil, c.errorf(pos, "function %s() cannot be compared", operand.name)
+		}
+		return operand, nil
+	}
+	return nil, c.errorf(pos, "expecting comparable")
+}
+
+// parseFunc parses name(args...), args are checked
+// against the param types
+func (c *jpParser) parseFunc(name string, start int) (*jpFunc, error) {
+	def, ok := jpFuncs[name]
+	if !ok {
+		return nil, c.errorf(start, "unknown function %s()", name)
+	}
+	c.pos++ // (
+	fn := &jpFunc{name: name, def: def}
+	c.skipSpace()
+	for !c.hasPrefix(")") {
+		if len(fn.args) > 0 {
+			if err := c.expect(","); err != nil {
+				return nil, err
+			}
+			c.skipSpace()
+		}
+		if len(fn.args) >= len(def.params) {
+			return nil, c.errorf(c.pos, "too many arguments for %s()", name)
+		}
+		arg, err := c.parseArg(def.params[len(fn.args)])
+		if err != nil {
+			return nil, err
+		}
+		fn.args = append(fn.args, arg)
+		c.skipSpace()
+	}
+	if len(fn.args) != len(def.params) {
+		return nil, c.errorf(c.pos, "%s() expects %d arguments, found %d", name, len(def.params), len(fn.args))
+	}
+	c.pos++ // )
+
+	// compile literal regexes once
+	if (name == "match" || name == "search") && len(fn.args) == 2 {
+		if lit, ok := fn.args[1].(jpLiteral); ok && jsonKind(lit.obj) == "string" {
+			pattern := lit.obj.(Primitive).StrValue()
+			full := name == "match"
+			if re := compileIRegexp(pattern, full); re != nil {
+				jpRegexps.Store(jpRegexpKey{pattern: pattern, full: full}, re)
+			}
+		}
+	}
+	return fn, nil
+}
+
+func (c *jpParser) parseArg(typ jpType) (jpArg, error) {
+	start := c.pos
+	if typ == jpLogicalType {
+		return c.parseOr()
+	}
+	operand, err := c.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case jpValueType:
+		return c.comparable(operand, start)
+	case jpNodesType:
+		switch operand := operand.(type) {
+		case *jpQuery:
+			return operand, nil
+		case *jpFunc:
+			if operand.def.result == jpNodesType {
+				return operand, nil
+			}
+		}
+		return nil, c.errorf(start, "expecting query as argument")
+	}
+	return nil, c.errorf(start, "unrecognized argument")
+}
@@ -0,0 +1,155 @@
+package objpath
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// the example of RFC 9535
+const testStore = `{
+	"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 399}
+	}
+}`
+
+func testJSONPath(t *testing.T, data string, cases map[string]string, sorted bool) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatal(err)
+	}
+	for expr, expect := range cases {
+		res, err := QueryJSONPath(v, expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		vals := make([]string, 0, len(res))
+		for _, obj := range res {
+			var val interface{}
+			if obj != nil {
+				val = obj.Value()
+			}
+			b, err := json.Marshal(val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vals = append(vals, string(b))
+		}
+		if sorted {
+			sort.Strings(vals)
+		}
+		s := "[" + strings.Join(vals, ",") + "]"
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", expr, `s`, expect, s)
+		}
+	}
+}
+
+// go test -run TestJSONPathStore -v ./
+func TestJSONPathStore(t *testing.T) {
+	testJSONPath(t, testStore, map[string]string{
+		`$.store.book[*].author`:                                  `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`,
+		`$.store.book[2]`:                                         `[{"author":"Herman Melville","category":"fiction","isbn":"0-553-21311-3","price":8.99,"title":"Moby Dick"}]`,
+		`$..book[2].author`:                                       `["Herman Melville"]`,
+		`$..book[2].publisher`:                                    `[]`,
+		`$..book[-1].title`:                                       `["The Lord of the Rings"]`,
+		`$..book[0,1].title`:                                      `["Sayings of the Century","Sword of Honour"]`,
+		`$..book[:2].title`:                                       `["Sayings of the Century","Sword of Honour"]`,
+		`$..book[?@.isbn].title`:                                  `["Moby Dick","The Lord of the Rings"]`,
+		`$..book[?@.price<10].title`:                              `["Sayings of the Century","Moby Dick"]`,
+		`$.store.book[?@.price < 10].title`:                       `["Sayings of the Century","Moby Dick"]`,
+		`$.store.book[?@.price > $.store.bicycle.price]`:          `[]`,
+		`$["store"]['bicycle']["color"]`:                          `["red"]`,
+		`$.store.book[::-1].author`:                               `["J. R. R. Tolkien","Herman Melville","Evelyn Waugh","Nigel Rees"]`,
+		`$.store.book[1:4:2].title`:                               `["Sword of Honour","The Lord of the Rings"]`,
+		`$.store.book[0:4:0]`:                                     `[]`,
+		`$.store.book[?@.category=='fiction' && !@.isbn].title`:   `["Sword of Honour"]`,
+		`$.store.book[?!(@.price<10 || @.price>20)].title`:        `["Sword of Honour"]`,
+		`$.store.book[?length(@.title) == 9].title`:               `["Moby Dick"]`,
+		`$.store.book[?match(@.author, 'J.*')].title`:             `["The Lord of the Rings"]`,
+		`$.store.book[?search(@.author, 'el')].author`:            `["Nigel Rees","Evelyn Waugh","Herman Melville"]`,
+		`$.store.book[?match(@.isbn, '[0-9-]+')].title`:           `["Moby Dick","The Lord of the Rings"]`,
+		`$.store[?count(@.*) > 4]`:                                `[]`,
+		`$.store[?count(@[*]) == 2].color`:                        `["red"]`,
+		`$.store.book[?value(@..isbn) == '0-553-21311-3'].title`:  `["Moby Dick"]`,
+		`$.store.book[?@.author == $.store.book[2].author].price`: `[8.99]`,
+	}, false)
+
+	testJSONPath(t, testStore, map[string]string{
+		`$.store.*`:      `[[{"author":"Nigel Rees","category":"reference","price":8.95,"title":"Sayings of the Century"},{"author":"Evelyn Waugh","category":"fiction","price":12.99,"title":"Sword of Honour"},{"author":"Herman Melville","category":"fiction","isbn":"0-553-21311-3","price":8.99,"title":"Moby Dick"},{"author":"J. R. R. Tolkien","category":"fiction","isbn":"0-395-19395-8","price":22.99,"title":"The Lord of the Rings"}],{"color":"red","price":399}]`,
+		`$.store..price`: `[12.99,22.99,399,8.95,8.99]`,
+		`$..author`:      `["Evelyn Waugh","Herman Melville","J. R. R. Tolkien","Nigel Rees"]`,
+		`$..*.color`:     `["red"]`,
+	}, true)
+}
+
+// go test -run TestJSONPathSemantics -v ./
+func TestJSONPathSemantics(t *testing.T) {
+	testJSONPath(t, `{"a":[3,5,1,2,4,6,{"b":"j"},{"b":"k"},{"b":{}},{"b":"kilo"}],"o":{"p":1,"q":2,"r":3,"s":5,"t":null},"e":[],"n":null,"0":"zero"}`, map[string]string{
+		`$.a[?@.b == 'kilo']`:       `[{"b":"kilo"}]`,
+		`$.a[?@>3.5]`:               `[5,4,6]`,
+		`$.a[?@.b]`:                 `[{"b":"j"},{"b":"k"},{"b":{}},{"b":"kilo"}]`,
+		`$.a[?@<2 || @.b == "k"]`:   `[1,{"b":"k"}]`,
+		`$.a[?match(@.b, "[jk]")]`:  `[{"b":"j"},{"b":"k"}]`,
+		`$.a[?search(@.b, "[jk]")]`: `[{"b":"j"},{"b":"k"},{"b":"kilo"}]`,
+		`$.a[?@ == @]`:              `[3,5,1,2,4,6,{"b":"j"},{"b":"k"},{"b":{}},{"b":"kilo"}]`,
+		`$.a[?@.x == @.y]`:          `[3,5,1,2,4,6,{"b":"j"},{"b":"k"},{"b":{}},{"b":"kilo"}]`,
+		`$.a[?@.b == $.x]`:          `[3,5,1,2,4,6]`,
+		`$.o.t`:                     `[null]`,
+		`$.n`:                       `[null]`,
+		`$[?@ == null]`:             `[null]`,
+		`$.o[?@ == null]`:           `[null]`,
+		`$.e[0]`:                    `[]`,
+		`$.a[0]`:                    `[3]`,
+		`$.a['0']`:                  `[]`,
+		`$['0']`:                    `["zero"]`,
+		`$.a[-1, 0, -100, 100]`:     `[{"b":"kilo"},3]`,
+		`$.a[2:0:-1]`:               `[1,5]`,
+		`$.a[-2:]`:                  `[{"b":{}},{"b":"kilo"}]`,
+		`$.a[?length(@.b) == 4].b`:  `["kilo"]`,
+		`$.a[?length(@) == 1].b`:    `["j","k",{},"kilo"]`,
+		`$.a[?@.b=="j" || (@ > 5)]`: `[6,{"b":"j"}]`,
+		`$ .a [ 0 ]`:                `[3]`,
+		`$["0"]`:                    `["zero"]`,
+	}, false)
+}
+
+// go test -run TestJSONPathSyntaxError -v ./
+func TestJSONPathSyntaxError(t *testing.T) {
+	invalid := []string{
+		``,
+		`@.a`,
+		`$.`,
+		`$.a.`,
+		`$[`,
+		`$[01]`,
+		`$[-0]`,
+		`$[?@.a == @.*]`,
+		`$[?@..a == 1]`,
+		`$[?length(@.*) == 1]`,
+		`$[?length(@.a)]`,
+		`$[?count(1) == 1]`,
+		`$[?match(@.a) ]`,
+		`$[?foo(@.a)]`,
+		`$[?!@.a == 1]`,
+		`$[?1]`,
+		`$['a\x']`,
+		`$ `,
+		`$.1a`,
+		`$[9007199254740992]`,
+		`$[?@.b == {}]`,
+	}
+	for _, expr := range invalid {
+		_, err := CompileJSONPath(expr)
+		if _, ok := err.(*PathSyntaxError); !ok {
+			t.Fatalf("%s: expect *PathSyntaxError, actual: %v", expr, err)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package objpath
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF      tokenKind = iota
+	tokKey                // bare key or value, escapes resolved
+	tokString             // quoted by ' or "
+	tokDot                // .
+	tokDotDot             // ..
+	tokLBracket           // [
+	tokRBracket           // ]
+	tokLBrace             // {
+	tokRBrace             // }
+	tokComma              // ,
+	tokOp                 // = != > >= < <= ^= $= *=
+	tokNot                // !
+	tokAnd                // &&
+	tokOr                 // ||
+	tokLParen             // (
+	tokRParen             // )
+	tokRegex              // ~/regex/, text is the regex
+)
+
+func (c tokenKind) String() string {
+	switch c {
+	case tokEOF:
+		return "end of path"
+	case tokKey:
+		return "key"
+	case tokString:
+		return "string"
+	case tokDot:
+		return "'.'"
+	case tokDotDot:
+		return "'..'"
+	case tokLBracket:
+		return "'['"
+	case tokRBracket:
+		return "']'"
+	case tokLBrace:
+		return "'{'"
+	case tokRBrace:
+		return "'}'"
+	case tokComma:
+		return "','"
+	case tokOp:
+		return "operator"
+	case tokNot:
+		return "'!'"
+	case tokAnd:
+		return "'&&'"
+	case tokOr:
+		return "'||'"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokRegex:
+		return "regex"
+	default:
+		return "unknown"
+	}
+}
+
+type token struct {
+	kind tokenKind
+	pos  int    // byte offset in path
+	end  int    // byte offset following the token
+	text string // unescaped text for key and string, raw text otherwise
+	// escaped is true if a key contains
+	// backslash escaped chars
+	escaped bool
+}
+
+// lexMode decides how chars are grouped into
+// tokens, because the same char means differently
+// at different places, e.g. '.' separates keys in
+// a path, but is part of the key inside [].
+type lexMode int
+
+const (
+	// modePath keys outside {} end at . [ ] { } ( )
+	modePath lexMode = iota
+	// modeBracket selectors inside [] end at , ]
+	modeBracket
+	// modeCond keys of paths inside {} additionally end
+	// at ',', '!', '(', ')', operators and spaces, spaces
+	// around are skipped
+	modeCond
+	// modeValue values inside {} end at , } ) && ||
+	modeValue
+	// modeArgs method call arguments inside () end at , ) and spaces
+	modeArgs
+)
+
+func (c lexMode) skipSpace() bool {
+	return c != modePath
+}
+
+// isStop tells whether src[i] terminates a bare key
+func (c lexMode) isStop(src string, i int) bool {
+	ch := src[i]
+	switch c {
+	case modePath:
+		return strings.IndexByte(".[]{}()", ch) >= 0
+	case modeBracket:
+		return ch == ',' || ch == ']'
+	case modeCond:
+		if strings.IndexByte(".[]{},=!<>() \t", ch) >= 0 {
+			return true
+		}
+		// ^= $= *=
+		if strings.IndexByte("^$*", ch) >= 0 && i+1 < len(src) && src[i+1] == '=' {
+			return true
+		}
+		return isLogicalOp(src, i)
+	case modeValue:
+		return ch == ',' || ch == '}' || ch == ')' || isLogicalOp(src, i)
+	case modeArgs:
+		return strings.IndexByte(",() \t", ch) >= 0
+	default:
+		return true
+	}
+}
+
+// lexer splits a path into tokens on demand,
+// the caller decides which mode is used
+type lexer struct {
+	src string
+	pos int
+
+	peeked     bool
+	peekedMode lexMode
+	peekedTok  token
+	peekedErr  error
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src}
+}
+
+func (c *lexer) peek(mode lexMode) (token, error) {
+	if c.peeked && c.peekedMode == mode {
+		return c.peekedTok, c.peekedErr
+	}
+	tok, err := c.scan(c.pos, mode)
+	c.peeked = true
+	c.peekedMode = mode
+	c.peekedTok = tok
+	c.peekedErr = err
+	return tok, err
+}
+
+func (c *lexer) next(mode lexMode) (token, error) {
+	tok, err := c.peek(mode)
+	if err != nil {
+		return tok, err
+	}
+	c.peeked = false
+	c.pos = tok.end
+	return tok, nil
+}
+
+func (c *lexer) scan(pos int, mode lexMode) (token, error) {
+	src := c.src
+	if mode.skipSpace() {
+		for pos < len(src) && isSpace(src[pos]) {
+			pos++
+		}
+	}
+	if pos >= len(src) {
+		return token{kind: tokEOF, pos: pos, end: pos}, nil
+	}
+	single := func(kind tokenKind) (token, error) {
+		return token{kind: kind, pos: pos, end: pos + 1, text: src[pos : pos+1]}, nil
+	}
+	ch := src[pos]
+	if mode == modeArgs && (ch == '{' || ch == '[') {
+		return c.scanJSON(pos)
+	}
+	switch ch {
+	case '\'', '"':
+		return c.scanQuoted(pos)
+	case '~':
+		if (mode == modePath || mode == modeCond) && pos+1 < len(src) && src[pos+1] == '/' {
+			return c.scanRegex(pos)
+		}
+	case '.':
+		if mode == modePath || mode == modeCond {
+			if pos+1 < len(src) && src[pos+1] == '.' {
+				return token{kind: tokDotDot, pos: pos, end: pos + 2, text: ".."}, nil
+			}
+			return single(tokDot)
+		}
+	case '[':
+		if mode == modePath || mode == modeCond {
+			return single(tokLBracket)
+		}
+	case ']':
+		if mode != modeValue && mode != modeArgs {
+			return single(tokRBracket)
+		}
+	case '{':
+		if mode == modePath || mode == modeCond {
+			return single(tokLBrace)
+		}
+	case '}':
+		if mode != modeArgs {
+			return single(tokRBrace)
+		}
+	case ',':
+		if mode != modePath {
+			return single(tokComma)
+		}
+	case '=':
+		if mode == modeCond {
+			return single(tokOp)
+		}
+	case '(':
+		if mode == modePath || mode == modeCond || mode == modeArgs {
+			return single(tokLParen)
+		}
+	case ')':
+		if mode != modeBracket {
+			return single(tokRParen)
+		}
+	case '&', '|':
+		if (mode == modeCond || mode == modeValue) && isLogicalOp(src, pos) {
+			kind := tokAnd
+			if ch == '|' {
+				kind = tokOr
+			}
+			return token{kind: kind, pos: pos, end: pos + 2, text: src[pos : pos+2]}, nil
+		}
+	case '!', '<', '>', '^', '$', '*':
+		// a.*=v is a wildcard followed by =,
+		// while a*=v means a contains v
+		if mode == modeCond && !(ch == '*' && startsKey(src, pos)) {
+			if pos+1 < len(src) && src[pos+1] == '=' {
+				return token{kind: tokOp, pos: pos, end: pos + 2, text: src[pos : pos+2]}, nil
+			}
+			switch ch {
+			case '!':
+				return single(tokNot)
+			case '<', '>':
+				return single(tokOp)
+			}
+		}
+	}
+	return c.scanKey(pos, mode)
+}
+
+// scanKey scans a bare key until a stop char of mode,
+// backslash escapes the following char.
+func (c *lexer) scanKey(pos int, mode lexMode) (token, error) {
+	src := c.src
+	var b strings.Builder
+	escaped := false
+	i := pos
+	for i < len(src) {
+		ch := src[i]
+		if ch == '\\' {
+			if i+1 >= len(src) {
+				return token{}, newPathSyntaxError(src, i, "missing char after '\\'")
+			}
+			escaped = true
+			b.WriteByte(src[i+1])
+			i += 2
+			continue
+		}
+		if mode.isStop(src, i) && !(i == pos && ch == '*') {
+			break
+		}
+		b.WriteByte(ch)
+		i++
+	}
+	text := b.String()
+	if mode == modeValue || mode == modeBracket {
+		// spaces around values are not significant
+		text = strings.TrimRightFunc(text, func(r rune) bool {
+			return r < 0x80 && isSpace(byte(r))
+		})
+	}
+	return token{kind: tokKey, pos: pos, end: i, text: text, escaped: escaped}, nil
+}
+
+// scanQuoted scans a string quoted by ' or ",
+// inside which backslash escapes the following char.
+func (c *lexer) scanQuoted(pos int) (token, error) {
+	src := c.src
+	quote := src[pos]
+	var b strings.Builder
+	for i := pos + 1; i < len(src); i++ {
+		ch := src[i]
+		if ch == '\\' {
+			if i+1 >= len(src) {
+				break
+			}
+			i++
+			b.WriteByte(src[i])
+			continue
+		}
+		if ch == quote {
+			return token{kind: tokString, pos: pos, end: i + 1, text: b.String()}, nil
+		}
+		b.WriteByte(ch)
+	}
+	return token{}, newPathSyntaxError(src, pos, "found %c, but missing closing %c", quote, quote)
+}
+
+// scanRegex scans ~/regex/, inside which \/ is an
+// escaped '/', other escapes are kept for the regex
+func (c *lexer) scanRegex(pos int) (token, error) {
+	src := c.src
+	var b strings.Builder
+	for i := pos + 2; i < len(src); i++ {
+		ch := src[i]
+		if ch == '\\' && i+1 < len(src) {
+			i++
+			if src[i] != '/' {
+				b.WriteByte(ch)
+			}
+			b.WriteByte(src[i])
+			continue
+		}
+		if ch == '/' {
+			return token{kind: tokRegex, pos: pos, end: i + 1, text: b.String()}, nil
+		}
+		b.WriteByte(ch)
+	}
+	return token{}, newPathSyntaxError(src, pos, "found ~/, but missing closing /")
+}
+
+// isLogicalOp tells whether src[i:] starts with && or ||
+func isLogicalOp(src string, i int) bool {
+	return (src[i] == '&' || src[i] == '|') && i+1 < len(src) && src[i+1] == src[i]
+}
+
+// startsKey tells whether a key starting at pos
+// would be the first key of a segment
+func startsKey(src string, pos int) bool {
+	if pos == 0 {
+		return true
+	}
+	return strings.IndexByte(".[{,!( \t", src[pos-1]) >= 0
+}
+
+// scanJSON scans a JSON object or array as raw text,
+// only brackets and strings are recognized, the
+// content is validated when decoded.
+func (c *lexer) scanJSON(pos int) (token, error) {
+	src := c.src
+	depth := 0
+	for i := pos; i < len(src); i++ {
+		switch src[i] {
+		case '"':
+			// skip string
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(src) {
+				return token{}, newPathSyntaxError(src, pos, "unterminated string in JSON")
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return token{kind: tokKey, pos: pos, end: i + 1, text: src[pos : i+1]}, nil
+			}
+		}
+	}
+	return token{}, newPathSyntaxError(src, pos, "found %c, but missing closing bracket", src[pos])
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
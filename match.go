@@ -0,0 +1,147 @@
+package objpath
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Match is a query result with the concrete keys
+// leading to it, e.g. querying orders.*.items.*{sku=X}
+// may give a Match with Path [orders 3 items 0].
+type Match struct {
+	// Path is the keys from the queried object to Object,
+	// nil if Object is not Located under the queried object
+	Path   []string
+	Object Object
+}
+
+// PathString returns Path as a path that can be
+// queried again, e.g. orders.3.items.0
+func (c Match) PathString() string {
+	return JoinPath(c.Path)
+}
+
+// QueryWithPaths is like Query, but records the
+// concrete path of every result
+func QueryWithPaths(v interface{}, path string) ([]Match, error) {
+	p, err := compilePathCached(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.QueryWithPaths(v)
+}
+
+// QueryWithPaths is like Query, but records the
+// concrete path of every result
+func (c *Path) QueryWithPaths(v interface{}) ([]Match, error) {
+	if v == nil {
+		return nil, nil
+	}
+	obj := NewObject(v)
+	objs, err := c.QueryObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]Match, 0, len(objs))
+	for _, o := range objs {
+		keys, _ := LocationOf(obj, o)
+		matches = append(matches, Match{
+			Path:   keys,
+			Object: o,
+		})
+	}
+	return matches, nil
+}
+
+// LocationOf returns the keys from `from` down to obj by
+// following the parents of obj, ok is false if from is
+// not an ancestor of obj. keys is empty if obj is from.
+func LocationOf(from Object, obj Object) (keys []string, ok bool) {
+	for obj != nil {
+		if sameObject(obj, from) {
+			// reverse
+			for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+				keys[i], keys[j] = keys[j], keys[i]
+			}
+			if keys == nil {
+				keys = []string{}
+			}
+			return keys, true
+		}
+		l, isLocated := obj.(Located)
+		if !isLocated {
+			return nil, false
+		}
+		keys = append(keys, l.Key())
+		obj = l.Parent()
+	}
+	return nil, false
+}
+
+func sameObject(a Object, b Object) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ta := reflect.TypeOf(a)
+	if ta != reflect.TypeOf(b) || !ta.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// JoinPath joins keys into a path, keys that
+// would be parsed differently are quoted,
+// e.g. [a b.c 0] gives a.'b.c'.0
+func JoinPath(keys []string) string {
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		if !needQuote(key) {
+			b.WriteString(key)
+			continue
+		}
+		b.WriteByte('\'')
+		for j := 0; j < len(key); j++ {
+			if key[j] == '\'' || key[j] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(key[j])
+		}
+		b.WriteByte('\'')
+	}
+	return b.String()
+}
+
+// needQuote tells whether key cannot be used as
+// a bare key, pseudo properties are kept bare
+// since they are usually located by pseudo queries
+func needQuote(key string) bool {
+	if key == "" || key == "^" || key == "$" || strings.HasPrefix(key, "~/") {
+		return true
+	}
+	if _, ok := lookupPseudoProperty(key); ok {
+		return false
+	}
+	return strings.ContainsAny(key, ".[]{}()'\"\\*")
+}
+
+// fieldOf describes where obj is for failure details, obj
+// is found by querying key from `from`. Objects outside
+// from are described from the root, e.g. $.a.b
+func fieldOf(from Object, obj Object, key string) string {
+	if keys, ok := LocationOf(from, obj); ok && len(keys) > 0 {
+		return JoinPath(keys)
+	}
+	root := RootOf(obj)
+	if root != nil && !sameObject(root, from) {
+		if keys, ok := LocationOf(root, obj); ok {
+			if len(keys) == 0 {
+				return "$"
+			}
+			return "$." + JoinPath(keys)
+		}
+	}
+	return key
+}
@@ -0,0 +1,132 @@
+package objpath
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// go test -run TestQueryWithPaths -v ./
+func TestQueryWithPaths(t *testing.T) {
+	v := map[string]interface{}{
+		"orders": []interface{}{
+			map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"sku": "A"},
+				},
+			},
+			map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"sku": "B"},
+					map[string]interface{}{"sku": "X"},
+				},
+			},
+		},
+		"a.b":  map[string]interface{}{"c": 1},
+		"user": testUser{First: "Ada", Last: "Lovelace"},
+	}
+	cases := map[string]string{
+		`orders.*.items.*{sku=X}`:  `[orders.1.items.1]`,
+		`orders.*.items.*.sku`:     `[orders.0.items.0.sku orders.1.items.0.sku orders.1.items.1.sku]`,
+		`orders[-1].items.$length`: `[orders.1.items.$length]`,
+		`['a.b'].c`:                `['a.b'.c]`,
+		`orders..sku{^.sku=A}.^.^`: `[orders.0.items]`,
+		`user.FullName()`:          `[user.FullName]`,
+		`$`:                        `[]`,
+		`orders.*.items.*{sku=Y}`:  `[]`,
+	}
+	for path, expect := range cases {
+		matches, err := QueryWithPaths(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		paths := make([]string, 0, len(matches))
+		for _, m := range matches {
+			paths = append(paths, m.PathString())
+		}
+		s := fmt.Sprintf("%v", paths)
+		if path == "$" {
+			if len(matches) != 1 || len(matches[0].Path) != 0 {
+				t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `matches`, "[root]", matches)
+			}
+			continue
+		}
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+		// the concrete path finds the same object
+		for _, m := range matches {
+			res, err := Query(v, m.PathString())
+			if err != nil {
+				t.Fatalf("%s: %v", m.PathString(), err)
+			}
+			if len(res) != 1 || fmt.Sprint(res[0]) != fmt.Sprint(m.Object) {
+				t.Fatalf("%s: expect %s = %+v, actual:%+v", m.PathString(), `res`, m.Object, res)
+			}
+		}
+	}
+}
+
+// go test -run TestJoinPath -v ./
+func TestJoinPath(t *testing.T) {
+	cases := map[string][]string{
+		`a.0.b`:         {"a", "0", "b"},
+		`'a.b'.'k*'`:    {"a.b", "k*"},
+		`'it\'s'.'\\'`:  {"it's", "\\"},
+		`'^'.'$'.''`:    {"^", "$", ""},
+		`a.$length`:     {"a", "$length"},
+		`'f()'.'[0]'.x`: {"f()", "[0]", "x"},
+	}
+	for expect, keys := range cases {
+		s := JoinPath(keys)
+		if s != expect {
+			t.Fatalf("expect %s = %+v, actual:%+v", `s`, expect, s)
+		}
+	}
+}
+
+// go test -run TestFilterConcreteField -v ./
+func TestFilterConcreteField(t *testing.T) {
+	v := map[string]interface{}{
+		"orders": []interface{}{
+			map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"sku": "A", "price": 1},
+				},
+			},
+			map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"sku": "X", "price": 2},
+				},
+			},
+		},
+	}
+	res := Check(v, `{"orders.*.items.*{sku=X}.price":"3"}`)
+	if res.Ok() || res[0].Field != "orders.1.items.0.price" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res[0].Field`, "orders.1.items.0.price", res)
+	}
+
+	res = Check(v, `{"orders.*.items.*":{"price":{"$gt":"5"}}}`)
+	if res.Ok() {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res.Ok()`, false, res.Ok())
+	}
+	var fields []string
+	for _, d := range res {
+		fields = append(fields, d.Field)
+	}
+	expect := "orders.0.items.0.price.$gt,orders.1.items.0.price.$gt"
+	if strings.Join(fields, ",") != expect {
+		t.Fatalf("expect %s = %+v, actual:%+v", `fields`, expect, fields)
+	}
+
+	res = Check(v, `{"orders[1].items[0]":{"$.orders[0].items[0].sku":"B"}}`)
+	if res.Ok() || res[0].Field != "$.orders.0.items.0.sku" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res[0].Field`, "$.orders.0.items.0.sku", res)
+	}
+
+	// nothing found, the pattern is reported
+	res = Check(v, `{"orders.*.items.*{sku=Y}.price":"3"}`)
+	if res.Ok() || res[0].Field != "orders.*.items.*{sku=Y}.price" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res[0].Field`, "orders.*.items.*{sku=Y}.price", res)
+	}
+}
@@ -1,11 +1,15 @@
 package objpath
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
-	"unicode"
+	"time"
+	"unsafe"
 )
 
 type Object interface {
@@ -16,6 +20,8 @@ type Object interface {
 type Primitive interface {
 	Object
 	StrValue() string
+	// Kind decides how the value is compared
+	Kind() PrimitiveKind
 }
 type Composite interface {
 	Object
@@ -24,41 +30,89 @@ type Composite interface {
 	GetChild(key string) (child Object, ok bool)
 }
 
-// don't respect JSONMarshaler and JSONUnmarshaler interface
+// don't respect JSONMarshaler and JSONUnmarshaler interface,
+// unless Options.UseMarshalers is set
 // NOTE: v cannot be reflect.Value
 func NewObject(v interface{}) Object {
+	return newObject(v, nil, 0)
+}
+
+// NewObjectWithOptions is like NewObject, opts
+// applies to all descendants as well
+func NewObjectWithOptions(v interface{}, opts *Options) Object {
+	return newObject(v, opts, 0)
+}
+
+func newObject(v interface{}, opts *Options, depth int) Object {
 	if v == nil {
 		return nil
 	}
 	rv := reflect.ValueOf(v)
-	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+	for {
+		if handler := opts.typeHandler(rv.Type()); handler != nil {
+			if obj, ok := handler(rv.Interface()); ok {
+				return obj
+			}
+		}
+		if rv.Kind() != reflect.Ptr && rv.Kind() != reflect.Interface {
+			break
+		}
 		if rv.IsNil() {
 			return nil
 		}
 		rv = rv.Elem()
 	}
+	if opts.useMarshalers() {
+		if marshaled, ok := marshal(rv); ok {
+			return newObject(marshaled, opts, depth)
+		}
+	}
+	if t, ok := timeOfValue(rv); ok {
+		return NewPrimitve(rv.Interface(), t.Format(time.RFC3339Nano))
+	}
+	if rv.Type() == durationType {
+		return NewPrimitve(rv.Interface(), time.Duration(rv.Int()).String())
+	}
+	b := base{
+		rv:    rv,
+		opts:  opts,
+		level: depth,
+		ident: identityOf(rv),
+	}
 	switch rv.Kind() {
 	case reflect.Array, reflect.Slice:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			// bytes are compared as string
+			return NewPrimitve(rv.Interface(), string(rv.Bytes()))
+		}
 		return &List{
-			base: base{
-				rv: rv,
-			},
+			base: b,
 		}
 	case reflect.Map:
 		return &Map{
-			base: base{
-				rv: rv,
-			},
+			base: b,
 		}
 	case reflect.Struct:
+		if opts.includeUnexported() && !rv.CanAddr() {
+			// unexported fields are read by address
+			cp := reflect.New(rv.Type()).Elem()
+			cp.Set(rv)
+			b.rv = cp
+			return &Struct{
+				base:   b,
+				copied: true,
+			}
+		}
 		return &Struct{
-			base: base{
-				rv: rv,
-			},
+			base: b,
 		}
 	case reflect.String:
-		iv := rv.Interface()
-		return NewPrimitve(iv, iv.(string))
+		return NewPrimitve(rv.Interface(), rv.String())
+	case reflect.Float32, reflect.Float64:
+		if format := opts.formatFloat(); format != nil {
+			return NewPrimitve(rv.Interface(), format(rv.Float(), rv.Type().Bits()))
+		}
+		return NewPrimitve(rv.Interface(), fmt.Sprint(rv.Interface()))
 	case reflect.Bool,
 		reflect.Int,
 		reflect.Int8,
@@ -69,9 +123,7 @@ func NewObject(v interface{}) Object {
 		reflect.Uint8,
 		reflect.Uint16,
 		reflect.Uint32,
-		reflect.Uint64,
-		reflect.Float32,
-		reflect.Float64:
+		reflect.Uint64:
 		return NewPrimitve(rv.Interface(), fmt.Sprint(rv.Interface()))
 	case reflect.Func, reflect.Chan:
 		return nil
@@ -84,6 +136,9 @@ type Struct struct {
 	base
 	m    *SortedMap // map[string]Object
 	once sync.Once
+	// copied is true if rv is an addressable copy of
+	// the value, its fields are not addressable in fact
+	copied bool
 }
 
 // Value implements Object
@@ -98,30 +153,57 @@ func (c *Struct) ChildrenLen() int {
 
 // GetChild implements Object
 func (c *Struct) GetChild(key string) (child Object, ok bool) {
-	v, ok := c.getChildren().GetOK(key)
+	m := c.getChildren()
+	v, ok := m.GetOK(key)
+	if !ok && c.opts.caseInsensitive() {
+		// the first one in fields order, like json.Unmarshal
+		m.Range(func(k string, val interface{}) bool {
+			if strings.EqualFold(k, key) {
+				v, ok = val, true
+				return false
+			}
+			return true
+		})
+	}
 	if !ok {
 		return nil, false
 	}
-	return v.(Object), true
+	child, _ = v.(Object)
+	return child, true
 }
 
 // RangeChildren implements Object
 func (c *Struct) RangeChildren(fn func(key string, child Object) bool) {
 	c.getChildren().Range(func(key string, val interface{}) bool {
-		return fn(key, val.(Object))
+		child, _ := val.(Object)
+		return fn(key, child)
 	})
 }
 func (c *Struct) getChildren() *SortedMap {
 	c.once.Do(func() {
-		c.m = NewSortedMap(c.rv.NumField())
-		TraverseStruct(c.rv, func(value reflect.Value, field, parentField *reflect.StructField) {
-			// about efficiency: given that overlapping does not happen so frequently
-			// we can just compute for each Object, they may be later discared
-			// due to name override
-			if field.Name != "" && unicode.ToUpper(rune(field.Name[0])) == rune(field.Name[0]) {
-				c.m.Set(field.Name, NewObject(value.Interface()))
+		if c.opts.exceedDepth(c.level) {
+			c.m = NewSortedMap(0)
+			return
+		}
+		fields := cachedStructFields(c.rv.Type(), c.opts.fieldNames(), c.opts.includeUnexported())
+		c.m = NewSortedMap(len(fields))
+		for _, field := range fields {
+			value, err := fieldByIndex(c.rv, field.index)
+			if err != nil {
+				// through nil embedded pointer
+				continue
 			}
-		})
+			if !value.CanInterface() {
+				// unexported, the struct is addressable
+				value = reflect.NewAt(value.Type(), unsafe.Pointer(value.UnsafeAddr())).Elem()
+			}
+			v := value.Interface()
+			if !c.copied {
+				v = c.opts.childValue(value)
+			}
+			// with Go names, a later field of the same name wins
+			c.m.Set(field.name, newChild(c, field.name, v))
+		}
 	})
 	return c.m
 }
@@ -139,7 +221,7 @@ var _ Object = ((*Map)(nil))
 
 // ChildrenLen implements Object
 func (c *Map) ChildrenLen() int {
-	return c.rv.Len()
+	return len(c.getChildren())
 }
 
 // GetChild implements Object
@@ -164,14 +246,37 @@ func (c *Map) Value() interface{} {
 
 func (c *Map) getChildren() map[string]Object {
 	c.once.Do(func() {
+		if c.opts.exceedDepth(c.level) {
+			c.m = map[string]Object{}
+			return
+		}
 		c.m = make(map[string]Object, c.rv.Len())
 		for it := c.rv.MapRange(); it.Next(); {
-			c.m[fmt.Sprint(it.Key())] = NewObject(it.Value().Interface())
+			key := mapKey(it.Key(), c.opts.useMarshalers())
+			c.m[key] = newChild(c, key, it.Value().Interface())
 		}
 	})
 	return c.m
 }
 
+// mapKey formats key of map, like encoding/json
+// when useMarshalers is set
+func mapKey(key reflect.Value, useMarshalers bool) string {
+	if useMarshalers && key.Kind() != reflect.String && key.Type().Implements(textMarshalerType) {
+		if text, err := key.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(key)
+}
+
+// listObject is implemented by List and JSONList,
+// whose children are indexed
+type listObject interface {
+	Composite
+	getChildren() []Object
+}
+
 type List struct {
 	base
 	list []Object
@@ -187,7 +292,7 @@ var _ Object = ((*List)(nil))
 
 // ChildrenLen implements Object
 func (c *List) ChildrenLen() int {
-	return c.rv.Len()
+	return len(c.getChildren())
 }
 
 // GetChild implements Object
@@ -222,16 +327,107 @@ func (c *List) RangeChildren(fn func(key string, child Object) bool) {
 func (c *List) getChildren() []Object {
 	c.once.Do(func() {
 		n := c.rv.Len()
+		if c.opts.exceedDepth(c.level) {
+			n = 0
+		}
 		c.list = make([]Object, n)
 		for i := 0; i < n; i++ {
-			c.list[i] = NewObject(c.rv.Index(i).Interface())
+			c.list[i] = newChild(c, strconv.Itoa(i), c.opts.childValue(c.rv.Index(i)))
 		}
 	})
 	return c.list
 }
 
 type base struct {
-	rv reflect.Value
+	rv    reflect.Value
+	opts  *Options // nil for default
+	level int      // depth from the top level object
+	ident identity // to detect cycles
+	loc
+}
+
+// Located is implemented by Objects that remember where
+// they are found, children of Struct, Map and List are
+// Located, the top level object has nil Parent.
+type Located interface {
+	Object
+	Parent() Object
+	// Key is the key of this object in Parent
+	Key() string
+}
+
+// locatable is implemented by Objects whose location
+// can be set when created as a child
+type locatable interface {
+	setLocation(parent Object, key string)
+}
+
+// loc implements Located
+type loc struct {
+	parent Object
+	key    string
+}
+
+func (c *loc) Parent() Object {
+	return c.parent
+}
+
+func (c *loc) Key() string {
+	return c.key
+}
+
+func (c *loc) setLocation(parent Object, key string) {
+	c.parent = parent
+	c.key = key
+}
+
+// ParentOf returns the parent of obj, nil if
+// obj is the top level object or is not Located
+func ParentOf(obj Object) Object {
+	l, ok := obj.(Located)
+	if !ok {
+		return nil
+	}
+	return l.Parent()
+}
+
+// RootOf follows the parents of obj up to
+// the top level object
+func RootOf(obj Object) Object {
+	for {
+		parent := ParentOf(obj)
+		if parent == nil {
+			return obj
+		}
+		obj = parent
+	}
+}
+
+// newChild creates the child Object of parent at key,
+// with the same options as parent, nil values are Null,
+// values that are also an ancestor are Ref
+func newChild(parent Object, key string, v interface{}) Object {
+	var opts *Options
+	var depth int
+	if o, ok := parent.(optioned); ok {
+		opts = o.options()
+		depth = o.depth()
+	}
+	child := newObject(v, opts, depth+1)
+	if child == nil {
+		child = NewNull(v)
+	} else if target := cycleTarget(parent, child); target != nil {
+		if l, ok := child.(locatable); ok {
+			// children of the view look for
+			// cycles from the same ancestors
+			l.setLocation(parent, key)
+		}
+		child = &Ref{val: v, target: target, view: child}
+	}
+	if l, ok := child.(locatable); ok {
+		l.setLocation(parent, key)
+	}
+	return child
 }
 
 func (c *base) String() string {
@@ -241,9 +437,50 @@ func (c *base) Method(name string) (method interface{}, ok bool) {
 	return MethodOf(c.rv, name)
 }
 
+// Null is a nil child of Struct, Map or List, like
+// a nil pointer field or a JSON null, so that a key
+// present but null is distinguished from a missing one.
+// It is neither Primitive nor Composite.
+type Null struct {
+	val interface{}
+	loc
+}
+
+var _ Object = ((*Null)(nil))
+
+// NewNull creates a Null, val is the nil value
+// it stands for, like (*T)(nil), or nil
+func NewNull(val interface{}) *Null {
+	return &Null{val: val}
+}
+
+// IsNull tells whether obj is nil or a Null
+func IsNull(obj Object) bool {
+	if obj == nil {
+		return true
+	}
+	_, ok := obj.(*Null)
+	return ok
+}
+
+// Value implements Object
+func (c *Null) Value() interface{} {
+	return c.val
+}
+
+// Method implements Object, Null has no methods
+func (c *Null) Method(name string) (method interface{}, ok bool) {
+	return nil, false
+}
+
+func (c *Null) String() string {
+	return "null"
+}
+
 type SPrimitive struct {
 	val interface{}
 	str string
+	loc
 }
 
 var _ Primitive = ((*SPrimitive)(nil))
@@ -264,6 +501,12 @@ func (c *SPrimitive) Value() interface{} {
 func (c *SPrimitive) StrValue() string {
 	return c.str
 }
+
+// Kind implements Primitive
+func (c *SPrimitive) Kind() PrimitiveKind {
+	return KindOf(c.val)
+}
+
 func (c *SPrimitive) String() string {
 	return c.str
 }
@@ -333,7 +576,7 @@ func CallFn(fn interface{}, args []string) (res []interface{}, err error) {
 		if i < n-1 || !variadic {
 			t = fnType.In(i)
 		} else {
-			t = fnType.In(n - 1)
+			t = fnType.In(n - 1).Elem()
 		}
 		v, err = createArg(args[i], t)
 		if err != nil {
@@ -363,21 +606,112 @@ func CallFn(fn interface{}, args []string) (res []interface{}, err error) {
 	return
 }
 
-// we can parse int
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
 
+// createArg converts arg to typ:
+//   - bool, int, uint and float kinds, with overflow checked
+//   - string kinds
+//   - time.Duration, like "5s" or nanoseconds
+//   - encoding.TextUnmarshaler
+//   - pointers to the above, "null" means nil pointer
+//   - struct, slice, array and map, decoded from JSON
+//   - interface{}, the string itself
+//
+// named types are converted from their underlying kind
 func createArg(arg string, typ reflect.Type) (reflect.Value, error) {
-	v := reflect.New(typ)
+	v := reflect.New(typ).Elem()
+	err := setArg(v, arg)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return v, nil
+}
+
+// parseDuration parses d like "5s", or nanoseconds
+func parseDuration(arg string) (time.Duration, error) {
+	d, err := time.ParseDuration(arg)
+	if err != nil {
+		n, nerr := strconv.ParseInt(arg, 10, 64)
+		if nerr != nil {
+			return 0, fmt.Errorf("parsing duration: %v %v", arg, err)
+		}
+		d = time.Duration(n)
+	}
+	return d, nil
+}
+
+// setArg sets arg to addressable v
+func setArg(v reflect.Value, arg string) error {
+	typ := v.Type()
+	if typ == durationType {
+		d, err := parseDuration(arg)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if typ.Kind() != reflect.Ptr && reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(arg))
+		if err != nil {
+			return fmt.Errorf("parsing %v: %v %v", typ, arg, err)
+		}
+		return nil
+	}
 	switch typ.Kind() {
-	case reflect.Int:
-		i, err := strconv.ParseInt(arg, 10, 64)
+	case reflect.Ptr:
+		if arg == "null" {
+			return nil
+		}
+		elem := reflect.New(typ.Elem())
+		err := setArg(elem.Elem(), arg)
+		if err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(arg, 10, typ.Bits())
+		if err != nil {
+			return fmt.Errorf("parsing number: %v %v", arg, err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(arg, 10, typ.Bits())
 		if err != nil {
-			return reflect.Value{}, fmt.Errorf("parsing number: %v %v", arg, err)
+			return fmt.Errorf("parsing number: %v %v", arg, err)
 		}
-		v.Elem().Set(reflect.ValueOf(int(i)))
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(arg, typ.Bits())
+		if err != nil {
+			return fmt.Errorf("parsing number: %v %v", arg, err)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return fmt.Errorf("parsing bool: %v %v", arg, err)
+		}
+		v.SetBool(b)
 	case reflect.String:
-		v.Elem().Set(reflect.ValueOf(arg))
+		v.SetString(arg)
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		err := json.Unmarshal([]byte(arg), v.Addr().Interface())
+		if err != nil {
+			return fmt.Errorf("parsing json: %v %v", arg, err)
+		}
+	case reflect.Interface:
+		if typ.NumMethod() != 0 {
+			return fmt.Errorf("unrecognized type:%v %v", arg, typ)
+		}
+		v.Set(reflect.ValueOf(arg))
 	default:
-		return reflect.Value{}, fmt.Errorf("unrecognized type:%v %v", arg, typ)
+		return fmt.Errorf("unrecognized type:%v %v", arg, typ)
 	}
-	return v.Elem(), nil
+	return nil
 }
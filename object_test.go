@@ -0,0 +1,87 @@
+package objpath
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testLevel int
+
+type testName string
+
+type testIP [4]byte
+
+func (c *testIP) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d.%d.%d.%d", &c[0], &c[1], &c[2], &c[3])
+	return err
+}
+
+type testPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// go test -run TestCallFnArgKinds -v ./
+func TestCallFnArgKinds(t *testing.T) {
+	cases := []struct {
+		fn     interface{}
+		args   []string
+		expect string
+	}{
+		{func(a int8, b int16, c int32, d int64) int64 { return int64(a) + int64(b) + int64(c) + d }, []string{"1", "2", "3", "-4"}, "[2]"},
+		{func(a uint, b uint8, c uint64) uint64 { return uint64(a) + uint64(b) + c }, []string{"1", "255", "18446744073709551000"}, "[18446744073709551256]"},
+		{func(a float32, b float64) float64 { return float64(a) + b }, []string{"0.5", "1e3"}, "[1000.5]"},
+		{func(b bool) bool { return !b }, []string{"true"}, "[false]"},
+		{func(l testLevel, n testName) string { return fmt.Sprintf("%d:%s", l, n) }, []string{"3", "x"}, "[3:x]"},
+		{func(d time.Duration) string { return d.String() }, []string{"1m30s"}, "[1m30s]"},
+		{func(d time.Duration) string { return d.String() }, []string{"1000"}, "[1µs]"},
+		{func(p *int) string { return fmt.Sprint(*p) }, []string{"7"}, "[7]"},
+		{func(p *int) bool { return p == nil }, []string{"null"}, "[true]"},
+		{func(ip testIP) string { return fmt.Sprint(ip) }, []string{"10.0.0.1"}, "[[10 0 0 1]]"},
+		{func(ip *testIP) string { return fmt.Sprint(*ip) }, []string{"10.0.0.2"}, "[[10 0 0 2]]"},
+		{func(p testPoint) int { return p.X * p.Y }, []string{`{"x":3,"y":4}`}, "[12]"},
+		{func(p *testPoint) int { return p.X + p.Y }, []string{`{"x":3,"y":4}`}, "[7]"},
+		{func(l []int) int { return len(l) }, []string{`[1,2,3]`}, "[3]"},
+		{func(m map[string]int) int { return m["a"] }, []string{`{"a":5}`}, "[5]"},
+		{func(v interface{}) string { return fmt.Sprintf("%T", v) }, []string{"x"}, "[string]"},
+		{func(prefix string, l ...testLevel) string { return fmt.Sprint(prefix, l) }, []string{"p", "1", "2"}, "[p[1 2]]"},
+	}
+	for i, c := range cases {
+		res, err := CallFn(c.fn, c.args)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		s := fmt.Sprint(res)
+		if s != c.expect {
+			t.Fatalf("case %d: expect %s = %+v, actual:%+v", i, `s`, c.expect, s)
+		}
+	}
+}
+
+// go test -run TestCallFnArgErrors -v ./
+func TestCallFnArgErrors(t *testing.T) {
+	cases := []struct {
+		fn     interface{}
+		args   []string
+		expect string
+	}{
+		{func(a int8) {}, []string{"128"}, "out of range"},
+		{func(a uint16) {}, []string{"-1"}, "invalid syntax"},
+		{func(a uint32) {}, []string{"4294967296"}, "out of range"},
+		{func(a float32) {}, []string{"1e39"}, "out of range"},
+		{func(a bool) {}, []string{"yes"}, "parsing bool"},
+		{func(d time.Duration) {}, []string{"5x"}, "parsing duration"},
+		{func(p testPoint) {}, []string{`{"x":"a"}`}, "parsing json"},
+		{func(ip testIP) {}, []string{"bad"}, "parsing objpath.testIP"},
+		{func(c chan int) {}, []string{"1"}, "unrecognized type"},
+		{func(e error) {}, []string{"1"}, "unrecognized type"},
+	}
+	for i, c := range cases {
+		_, err := CallFn(c.fn, c.args)
+		if err == nil || !strings.Contains(err.Error(), c.expect) {
+			t.Fatalf("case %d: expect error %q, actual: %v", i, c.expect, err)
+		}
+	}
+}
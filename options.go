@@ -0,0 +1,384 @@
+package objpath
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Options controls how Objects are created from Go values,
+// nil Options means the default of each option.
+type Options struct {
+	// FieldNames decides the keys of Struct children
+	FieldNames FieldNaming
+	// CaseInsensitive makes GetChild of Struct fall
+	// back to case-insensitive match of keys when
+	// no key matches exactly, like json.Unmarshal
+	CaseInsensitive bool
+	// IncludeUnexported keys unexported struct fields
+	// by their Go names as well
+	IncludeUnexported bool
+	// FormatFloat formats float primitives, bitSize is 32
+	// or 64, nil means fmt.Sprint
+	FormatFloat func(f float64, bitSize int) string
+	// MaxDepth limits the depth of Objects that have
+	// children, the root is at depth 0, Objects at
+	// MaxDepth have no children. 0 means no limit.
+	MaxDepth int
+	// TypeHandlers creates Objects for values of given types,
+	// checked before pointers and interfaces are dereferenced,
+	// a handler returning false falls back to the default.
+	TypeHandlers map[reflect.Type]TypeHandler
+	// UseMarshalers replaces values implementing json.Marshaler
+	// or encoding.TextMarshaler with their marshaled form, e.g.
+	// time.Time becomes a string, json.RawMessage becomes the
+	// decoded Composite with numbers as json.Number. Values
+	// failing to marshal are kept as is.
+	UseMarshalers bool
+}
+
+// TypeHandler creates the Object for v, e.g. to expose
+// a func or chan, or to present a type as a Primitive
+type TypeHandler func(v interface{}) (obj Object, ok bool)
+
+// FieldNaming decides the keys of Struct children
+type FieldNaming int
+
+const (
+	// FieldNamesGo keys fields by Go names, fields of embedded
+	// structs are flattened in declaration order, a later field
+	// replaces the value of an earlier one of the same name.
+	FieldNamesGo FieldNaming = iota
+	// FieldNamesJSON keys fields by json tag names, the same
+	// as what encoding/json marshals: fields tagged "-" are
+	// hidden, tagged embedded structs are not flattened, and
+	// among fields of the same name at the same depth the
+	// tagged one wins.
+	FieldNamesJSON
+)
+
+func (c *Options) fieldNames() FieldNaming {
+	if c == nil {
+		return FieldNamesGo
+	}
+	return c.FieldNames
+}
+
+func (c *Options) caseInsensitive() bool {
+	return c != nil && c.CaseInsensitive
+}
+
+func (c *Options) includeUnexported() bool {
+	return c != nil && c.IncludeUnexported
+}
+
+func (c *Options) useMarshalers() bool {
+	return c != nil && c.UseMarshalers
+}
+
+func (c *Options) formatFloat() func(f float64, bitSize int) string {
+	if c == nil {
+		return nil
+	}
+	return c.FormatFloat
+}
+
+// exceedDepth tells whether Objects at depth have no children
+func (c *Options) exceedDepth(depth int) bool {
+	return c != nil && c.MaxDepth > 0 && depth >= c.MaxDepth
+}
+
+func (c *Options) typeHandler(t reflect.Type) TypeHandler {
+	if c == nil || len(c.TypeHandlers) == 0 {
+		return nil
+	}
+	return c.TypeHandlers[t]
+}
+
+// marshal returns the marshaled form of rv if it implements
+// json.Marshaler or encoding.TextMarshaler. Like encoding/json,
+// the json form takes precedence, and methods of pointer
+// receivers are used only if rv is addressable.
+func marshal(rv reflect.Value) (v interface{}, ok bool) {
+	recv := rv.Interface()
+	if rv.CanAddr() {
+		recv = rv.Addr().Interface()
+	}
+	if m, isJSON := recv.(json.Marshaler); isJSON {
+		data, err := m.MarshalJSON()
+		if err != nil {
+			return nil, false
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, false
+		}
+		if v == nil {
+			// null is nil
+			return nil, true
+		}
+		return v, true
+	}
+	m, isText := recv.(encoding.TextMarshaler)
+	if !isText {
+		return nil, false
+	}
+	text, err := m.MarshalText()
+	if err != nil {
+		return nil, false
+	}
+	return string(text), true
+}
+
+// childValue is the value of a field or element rv to create
+// its Object. The address of rv is used if rv is addressable
+// and its pointer implements a marshaler, so that marshal can
+// call it, like encoding/json does for addressable values.
+func (c *Options) childValue(rv reflect.Value) interface{} {
+	if !c.useMarshalers() || !rv.CanAddr() || rv.Kind() == reflect.Ptr {
+		return rv.Interface()
+	}
+	ptr := reflect.PtrTo(rv.Type())
+	if ptr.Implements(jsonMarshalerType) || ptr.Implements(textMarshalerType) {
+		return rv.Addr().Interface()
+	}
+	return rv.Interface()
+}
+
+// optioned is implemented by Objects created with options
+type optioned interface {
+	options() *Options
+	depth() int
+}
+
+func (c *base) options() *Options {
+	return c.opts
+}
+
+func (c *base) depth() int {
+	return c.level
+}
+
+// newObjectFrom creates an Object with the same options
+// and at the same depth as obj, e.g. method call results
+func newObjectFrom(obj Object, v interface{}) Object {
+	var opts *Options
+	var depth int
+	if o, ok := obj.(optioned); ok {
+		opts = o.options()
+		depth = o.depth()
+	}
+	return newObject(v, opts, depth)
+}
+
+// structField is a key of Struct children
+type structField struct {
+	name   string
+	index  []int // index sequence for reflect.Value.Field
+	tagged bool  // name is given by tag
+	typ    reflect.Type
+}
+
+type structFieldsKey struct {
+	typ        reflect.Type
+	naming     FieldNaming
+	unexported bool
+}
+
+var structFieldsCache sync.Map // structFieldsKey -> []structField
+
+func cachedStructFields(t reflect.Type, naming FieldNaming, unexported bool) []structField {
+	key := structFieldsKey{typ: t, naming: naming, unexported: unexported}
+	if fields, ok := structFieldsCache.Load(key); ok {
+		return fields.([]structField)
+	}
+	fields, _ := structFieldsCache.LoadOrStore(key, structFields(t, naming, unexported))
+	return fields.([]structField)
+}
+
+// structFields returns the keyed fields of struct t,
+// unexported fields are included if unexported is true
+func structFields(t reflect.Type, naming FieldNaming, unexported bool) []structField {
+	if naming == FieldNamesJSON {
+		return jsonStructFields(t, unexported)
+	}
+	return goStructFields(nil, t, nil, unexported, map[reflect.Type]bool{})
+}
+
+// goStructFields appends the fields of struct t keyed by
+// Go names, embedded structs are flattened depth first in
+// declaration order. Fields of the same name are all kept,
+// the last one replaces the others when children are built.
+func goStructFields(fields []structField, t reflect.Type, parent []int, unexported bool, visiting map[reflect.Type]bool) []structField {
+	visiting[t] = true
+	defer delete(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := make([]int, len(parent)+1)
+		copy(index, parent)
+		index[len(parent)] = i
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !visiting[ft] {
+				fields = goStructFields(fields, ft, index, unexported, visiting)
+			}
+			continue
+		}
+		if sf.PkgPath != "" && !unexported {
+			continue
+		}
+		fields = append(fields, structField{name: sf.Name, index: index, typ: sf.Type})
+	}
+	return fields
+}
+
+// jsonStructFields returns the fields of struct t keyed by
+// json names in declaration order, it follows typeFields of
+// encoding/json: embedded structs are expanded breadth first,
+// then among fields of the same name the dominant one is kept.
+func jsonStructFields(t reflect.Type, unexported bool) []structField {
+	var current []structField
+	next := []structField{{typ: t}}
+
+	// number of times each type is embedded at the current
+	// and the next depth
+	var count map[reflect.Type]int
+	nextCount := map[reflect.Type]int{}
+
+	visited := map[reflect.Type]bool{}
+
+	var fields []structField
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				exported := sf.PkgPath == ""
+				if sf.Anonymous {
+					// unexported embedded structs still
+					// promote their exported fields
+					if !exported && ft.Kind() != reflect.Struct && !unexported {
+						continue
+					}
+				} else if !exported && !unexported {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name := ""
+				if exported && tag != "" && !strings.HasPrefix(tag, ",") {
+					name, _ = GetExportedJSONName(&sf)
+				}
+
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+					fields = append(fields, structField{
+						name:   name,
+						index:  index,
+						tagged: tagged,
+						typ:    ft,
+					})
+					if count[f.typ] > 1 {
+						// the same type embedded multiple times at the
+						// same depth, add a duplicate to annihilate
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				// expand embedded struct at next depth
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, structField{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+
+	// keep the dominant field of each name
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		name := fields[i].name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fields[i])
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+	fields = out
+
+	sort.Slice(fields, func(i, j int) bool {
+		return indexLess(fields[i].index, fields[j].index)
+	})
+	return fields
+}
+
+// dominantField returns the dominant field among fields
+// of the same name sorted by depth then tagged first, there
+// is none if the first two are equally dominant.
+func dominantField(fields []structField) (structField, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return structField{}, false
+	}
+	return fields[0], true
+}
+
+func indexLess(a []int, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
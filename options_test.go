@@ -0,0 +1,319 @@
+package objpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testAccount struct {
+	Name    string
+	secret  string
+	balance float64
+	inner   testAccountInner
+}
+
+type testAccountInner struct {
+	code int
+}
+
+func strValues(objs []Object) string {
+	vals := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		if p, ok := obj.(Primitive); ok {
+			vals = append(vals, p.StrValue())
+		} else {
+			vals = append(vals, fmt.Sprint(obj))
+		}
+	}
+	return fmt.Sprint(vals)
+}
+
+// go test -run TestIncludeUnexported -v ./
+func TestIncludeUnexported(t *testing.T) {
+	v := testAccount{Name: "a", secret: "s", balance: 1.5, inner: testAccountInner{code: 7}}
+
+	res, err := Query(v, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[]", strValues(res))
+	}
+
+	opts := &Options{IncludeUnexported: true}
+	for path, expect := range map[string]string{
+		"secret":     "[s]",
+		"balance":    "[1.5]",
+		"inner.code": "[7]",
+		"*":          "[a s 1.5 {7}]",
+	} {
+		// both value and pointer
+		for _, x := range []interface{}{v, &v} {
+			res, err := QueryWithOptions(x, path, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := strValues(res)
+			if s != expect {
+				t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `res`, expect, s)
+			}
+		}
+	}
+	CheckWithOptions(v, `{"secret":"s","inner.code":7}`, opts).VerifyT(t)
+}
+
+// go test -run TestFormatFloat -v ./
+func TestFormatFloat(t *testing.T) {
+	v := map[string]interface{}{
+		"a": 1.0 / 3,
+		"b": float32(2.5),
+		"c": 3,
+	}
+	opts := &Options{
+		FormatFloat: func(f float64, bitSize int) string {
+			return strconv.FormatFloat(f, 'f', 2, bitSize)
+		},
+	}
+	res, err := QueryWithOptions(v, "[a,b,c]", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strValues(res)
+	expect := "[0.33 2.50 3]"
+	if s != expect {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, expect, s)
+	}
+	CheckWithOptions(v, `{"a":"0.33"}`, opts).VerifyT(t)
+	if CheckWithOptions(v, `{"a":"0.33"}`, nil).Ok() {
+		t.Fatalf("expect %s = %+v, actual:%+v", `ok`, false, true)
+	}
+}
+
+// go test -run TestMaxDepth -v ./
+func TestMaxDepth(t *testing.T) {
+	v := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": []int{1, 2},
+		},
+		"x": 1,
+	}
+	for _, c := range []struct {
+		maxDepth int
+		path     string
+		expect   string
+	}{
+		{0, "a.b.*", "[1 2]"},
+		{1, "x", "[1]"},
+		{1, "a.b", "[]"},
+		{2, "a.b.$length", "[0]"},
+		{2, "a.b.0", "[]"},
+		{3, "a.b.0", "[1]"},
+		{3, "a.b.$length", "[2]"},
+	} {
+		res, err := QueryWithOptions(v, c.path, &Options{MaxDepth: c.maxDepth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := strValues(res)
+		if s != c.expect {
+			t.Fatalf("%d %s: expect %s = %+v, actual:%+v", c.maxDepth, c.path, `res`, c.expect, s)
+		}
+	}
+}
+
+type testCounter struct {
+	N  int
+	Fn func() int
+}
+
+type testCents int
+
+// go test -run TestTypeHandlers -v ./
+func TestTypeHandlers(t *testing.T) {
+	v := &testCounter{
+		N:  2,
+		Fn: func() int { return 3 },
+	}
+	opts := &Options{
+		TypeHandlers: map[reflect.Type]TypeHandler{
+			reflect.TypeOf(func() int { return 0 }): func(v interface{}) (Object, bool) {
+				fn := v.(func() int)
+				if fn == nil {
+					return nil, false
+				}
+				n := fn()
+				return NewPrimitve(n, fmt.Sprint(n)), true
+			},
+			reflect.TypeOf(testCents(0)): func(v interface{}) (Object, bool) {
+				c := v.(testCents)
+				return NewPrimitve(c, fmt.Sprintf("%d.%02d", c/100, c%100)), true
+			},
+		},
+	}
+	res, err := QueryWithOptions(v, "Fn", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(res); s != "[3]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[3]", s)
+	}
+	res, err = Query(v, "Fn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || !IsNull(res[0]) {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[null]", res)
+	}
+
+	// handlers apply to children of maps and lists
+	prices := map[string][]testCents{"a": {150, 2005}}
+	CheckWithOptions(prices, `{"a.0":"1.50","a.1":"20.05"}`, opts).VerifyT(t)
+}
+
+type testNamedString string
+
+// go test -run TestNamedStringPrimitive -v ./
+func TestNamedStringPrimitive(t *testing.T) {
+	obj := NewObject(testNamedString("x"))
+	p, ok := obj.(Primitive)
+	if !ok || p.StrValue() != "x" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `obj`, "x", obj)
+	}
+	if _, ok := p.Value().(testNamedString); !ok {
+		t.Fatalf("expect %s = %+v, actual:%T", `type`, "testNamedString", p.Value())
+	}
+}
+
+type testStatus int
+
+func (c testStatus) MarshalText() ([]byte, error) {
+	switch c {
+	case 1:
+		return []byte("active"), nil
+	case 2:
+		return []byte("closed"), nil
+	}
+	return nil, fmt.Errorf("unknown status: %d", int(c))
+}
+
+type testMoney struct {
+	cents int64
+}
+
+func (c *testMoney) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"amount":"%d.%02d","currency":"USD"}`, c.cents/100, c.cents%100)), nil
+}
+
+type testOrder struct {
+	ID       int64
+	Status   testStatus
+	Price    testMoney
+	Raw      json.RawMessage
+	Created  time.Time
+	ByStatus map[testStatus]int
+}
+
+// go test -run TestUseMarshalers -v ./
+func TestUseMarshalers(t *testing.T) {
+	v := &testOrder{
+		ID:       1,
+		Status:   2,
+		Price:    testMoney{cents: 1050},
+		Raw:      json.RawMessage(`{"n":12345678901234567890,"l":[1,"x",null]}`),
+		Created:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		ByStatus: map[testStatus]int{1: 3},
+	}
+	opts := &Options{UseMarshalers: true}
+	for path, expect := range map[string]string{
+		"Status":          "[closed]",
+		"Price.amount":    "[10.50]",
+		"Raw.n":           "[12345678901234567890]",
+		"Raw.l.*":         "[1 x null]",
+		"Raw.n.$type":     "[number]",
+		"Created":         "[2024-01-02T03:04:05Z]",
+		"ByStatus.active": "[3]",
+	} {
+		res, err := QueryWithOptions(v, path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := strValues(res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `res`, expect, s)
+		}
+	}
+	CheckWithOptions(v, `{"Status":"closed","Price.currency":"USD"}`, opts).VerifyT(t)
+
+	// not by default
+	res, err := Query(v, "Status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(res); s != "[2]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[2]", s)
+	}
+
+	// values failing to marshal are kept
+	res, err = QueryWithOptions(testStatus(3), "$", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(res); s != "[3]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[3]", s)
+	}
+}
+
+// go test -run TestUseMarshalersLikeJSON -v ./
+func TestUseMarshalersLikeJSON(t *testing.T) {
+	money := testMoney{cents: 1050}
+	order := testOrder{Status: 1, Price: money}
+	opts := &Options{UseMarshalers: true}
+	// pointer receivers are used only for addressable values
+	for _, c := range []struct {
+		v    interface{}
+		path string
+	}{
+		{&order, "Price.amount"},
+		{order, "Price.amount"},
+		{[]testMoney{money}, "0.amount"},
+		{[1]testMoney{money}, "0.amount"},
+		{&[1]testMoney{money}, "0.amount"},
+		{map[string]testMoney{"a": money}, "a.amount"},
+		{map[string]*testMoney{"a": &money}, "a.amount"},
+		{map[string]interface{}{"a": money}, "a.amount"},
+		{map[string]interface{}{"a": []testMoney{money}}, "a.0.amount"},
+		{money, "amount"},
+		{&money, "amount"},
+	} {
+		data, err := json.Marshal(c.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsonRes, err := QueryJSON(data, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := QueryWithOptions(c.v, c.path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := strValues(jsonRes)
+		s := strValues(res)
+		if s != expect {
+			t.Fatalf("%T %s: expect %s = %+v, actual:%+v", c.v, c.path, `res`, expect, s)
+		}
+	}
+
+	// fields of a copied struct are not addressable either
+	res, err := QueryWithOptions(order, "Price.amount", &Options{UseMarshalers: true, IncludeUnexported: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strValues(res); s != "[]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res`, "[]", s)
+	}
+}
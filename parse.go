@@ -2,124 +2,862 @@ package objpath
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// PathSyntaxError reports a syntax error in a path
+type PathSyntaxError struct {
+	Path   string
+	Offset int // byte offset in Path where the error occurs
+	Msg    string
+}
+
+func newPathSyntaxError(path string, offset int, format string, args ...interface{}) *PathSyntaxError {
+	return &PathSyntaxError{
+		Path:   path,
+		Offset: offset,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// Error implements error, the message points
+// to the error position with a caret:
+//
+//	invalid syntax at offset 3: found '[', but missing ']'
+//	    a.b[c
+//	       ^
+func (c *PathSyntaxError) Error() string {
+	return fmt.Sprintf("invalid syntax at offset %d: %s\n%s", c.Offset, c.Msg, c.Annotate())
+}
+
+// Annotate returns the path with a caret under the error position
+func (c *PathSyntaxError) Annotate() string {
+	offset := c.Offset
+	if offset > len(c.Path) {
+		offset = len(c.Path)
+	}
+	col := utf8.RuneCountInString(c.Path[:offset])
+	return "    " + c.Path + "\n    " + strings.Repeat(" ", col) + "^"
+}
+
+// pathNode is the AST of a path
+//
+// grammar:
+//
+//	path      = [ "." ] segment { ( "." | ".." ) segment | bracket }
+//	segment   = key [ call ] { brace } | bracket | brace { brace }
+//	key       = bare | quoted
+//	call      = "(" [ arg { "," arg } ] ")"
+//	arg       = quoted | number | "true" | "false" | "null" | json
+//	bracket   = "[" selector { "," selector } "]" { brace }
+//	selector  = quoted | index | slice | bare
+//	brace     = "{" [ or ] "}"
+//	or        = and { "||" and }
+//	and       = unary { ( "&&" | "," ) unary }
+//	unary     = "!" unary | "(" or ")" | condition
+//	condition = path [ op value ]
+//	op        = "=" | "!=" | ">" | ">=" | "<" | "<=" | "^=" | "$=" | "*="
+//
 // example: a{k=v}
 // example: a.[x,y,z]
+// example: a..id, find id at any depth under a
+// example: a[-1], a[1:4], a[::2]
+// example: a[0,2,5], a.[x,y]
+// example: a.'k*', a["x.y"], a.x\.y
+// example: items.*{price>10,name^=foo,tag!=x}, items.*{!deleted}
+// example: orders.*{status=paid || (status=pending && retries>3)}
+// example: user.FullName(), cache.Get("k"), m.Lookup(3, "x")
+type pathNode struct {
+	pos      int
+	segments []*segmentNode
+}
+
+type segmentKind int
+
+const (
+	segKey     segmentKind = iota // a, 'a', a*, a{...}, {...}
+	segDescent                    // ..
+	segBracket                    // [x,y], [0:2]
+	segParent                     // ^, ^{...}
+	segRoot                       // $, ${...}
+)
+
+type segmentNode struct {
+	kind segmentKind
+	pos  int
+	end  int
+
+	// segKey, empty key means any
+	key   string
+	exact bool // quoted or escaped, not a glob
+	regex bool // ~/regex/, key is the regex
+
+	// segKey followed by (), key is the method name
+	call bool
+	args []string
+
+	// segBracket
+	selectors []*selectorNode
+
+	// conditions following the key or the bracket,
+	// all must hold
+	conds []condNode
+}
+
+type selectorNode struct {
+	pos   int
+	text  string
+	exact bool
+}
+
+type condNode interface {
+	condPos() int
+}
+
+// compareNode path op value, or
+// just path to check existence
+type compareNode struct {
+	pos   int
+	path  *pathNode
+	op    string // empty if no value
+	value string
+}
+
+func (c *compareNode) condPos() int {
+	return c.pos
+}
+
+// andNode cond && cond ...
+type andNode struct {
+	pos   int
+	conds []condNode
+}
+
+func (c *andNode) condPos() int {
+	return c.pos
+}
+
+// orNode cond || cond ...
+type orNode struct {
+	pos   int
+	conds []condNode
+}
+
+func (c *orNode) condPos() int {
+	return c.pos
+}
+
+// notNode !cond
+type notNode struct {
+	pos  int
+	cond condNode
+}
+
+func (c *notNode) condPos() int {
+	return c.pos
+}
+
+// condOps maps operators in {} to Op
+var condOps = map[string]Op{
+	"=":  OpEq,
+	"!=": OpNeq,
+	">":  OpGt,
+	">=": OpGe,
+	"<":  OpLt,
+	"<=": OpLe,
+	"^=": OpStartsWith,
+	"$=": OpEndsWith,
+	"*=": OpContains,
+}
+
 func parsePath(path string) ([]pathExpr, error) {
-	var exprs []pathExpr
+	node, err := parsePathAST(path)
+	if err != nil {
+		return nil, err
+	}
+	return compilePath(path, node)
+}
+
+func parsePathAST(path string) (*pathNode, error) {
+	p := &parser{
+		src: path,
+		lex: newLexer(path),
+	}
+	node, err := p.parsePath(modePath)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := p.lex.next(modePath)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind != tokEOF {
+		return nil, p.unexpected(tok)
+	}
+	return node, nil
+}
+
+type parser struct {
+	src string
+	lex *lexer
+}
+
+func (c *parser) errorf(pos int, format string, args ...interface{}) error {
+	return newPathSyntaxError(c.src, pos, format, args...)
+}
+
+func (c *parser) unexpected(tok token) error {
+	if tok.kind == tokKey || tok.kind == tokString || tok.kind == tokOp {
+		return c.errorf(tok.pos, "unexpected %s", strconv.Quote(c.src[tok.pos:tok.end]))
+	}
+	return c.errorf(tok.pos, "unexpected %s", tok.kind)
+}
+
+// parsePath parses segments until a token that cannot
+// continue the path, which is left to the caller
+func (c *parser) parsePath(mode lexMode) (*pathNode, error) {
+	node := &pathNode{pos: c.lex.pos}
+
+	// leading '.' is optional: .a, .[a]
+	tok, err := c.lex.peek(mode)
+	if err != nil {
+		return nil, err
+	}
+	node.pos = tok.pos
+	if tok.kind == tokDot {
+		c.lex.next(mode)
+	}
+	// whether a segment is required
+	needSegment := true
 	for {
-		// in each iteration, we
-		// find the following:
-		//  A.B
-		//  A{}.B
-		//  A.[BB]
-		if path == "" {
-			return exprs, nil
-		}
-		// []
-		// a.[]
-		// a[]
-		// .[]
-
-		// lookup special index: .{[
-		idx := strings.IndexAny(path, "{[.")
-		if idx < 0 {
-			// end
-			exprs = append(exprs, literalField(path))
-			return exprs, nil
-		}
-
-		// A{}
-		// TODO: support {}{}
-		if path[idx] == '{' {
-			eidx, err := lookClose(path, idx, "{", "}")
+		tok, err := c.lex.peek(mode)
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokKey, tokString, tokRegex, tokLBrace:
+			if !needSegment {
+				if tok.kind == tokLBrace {
+					return nil, c.unexpected(tok)
+				}
+				return node, nil
+			}
+			seg, err := c.parseKeySegment(mode)
 			if err != nil {
 				return nil, err
 			}
+			node.segments = append(node.segments, seg)
+			needSegment = false
+		case tokLBracket:
+			seg, err := c.parseBracketSegment(mode)
+			if err != nil {
+				return nil, err
+			}
+			node.segments = append(node.segments, seg)
+			needSegment = false
+		case tokDot, tokDotDot:
+			if needSegment {
+				return nil, c.errorf(tok.pos, "expecting key, found %s", tok.kind)
+			}
+			c.lex.next(mode)
+			if tok.kind == tokDotDot {
+				node.segments = append(node.segments, &segmentNode{kind: segDescent, pos: tok.pos, end: tok.end})
+			}
+			needSegment = true
+		default:
+			if needSegment && len(node.segments) > 0 {
+				return nil, c.errorf(tok.pos, "expecting key, found %s", tok.kind)
+			}
+			return node, nil
+		}
+	}
+}
 
-			key := path[:idx]
-			kvs := path[idx+1 : eidx]
-			path = path[eidx+1:]
+// parseKeySegment parses key{...}{...}, key may be
+// omitted if followed by {}
+func (c *parser) parseKeySegment(mode lexMode) (*segmentNode, error) {
+	tok, err := c.lex.peek(mode)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segmentNode{kind: segKey, pos: tok.pos}
+	if tok.kind == tokRegex {
+		c.lex.next(mode)
+		seg.key = tok.text
+		seg.regex = true
+	} else if tok.kind == tokKey || tok.kind == tokString {
+		c.lex.next(mode)
+		seg.key = tok.text
+		seg.exact = tok.kind == tokString || tok.escaped
+		if tok.kind == tokKey && !tok.escaped {
+			switch tok.text {
+			case "^":
+				seg.kind = segParent
+			case "$":
+				seg.kind = segRoot
+			}
+		}
 
-			condition := parsePairs(kvs)
-			exprs = append(exprs, &variableField{
-				field:     key,
-				condition: condition,
-			})
-			continue
+		// method call: key(...)
+		paren, err := c.lex.peek(mode)
+		if err != nil {
+			return nil, err
 		}
-		if path[idx] == '.' {
-			// TODO: don't allow A..B, allow only A.B, A.[B]
-			if idx > 0 {
-				// non-empty
-				exprs = append(exprs, literalField(path[:idx]))
+		if paren.kind == tokLParen && paren.pos == tok.end && seg.kind == segKey {
+			c.lex.next(mode)
+			seg.call = true
+			seg.args, err = c.parseArgs(paren)
+			if err != nil {
+				return nil, err
 			}
-			path = path[idx+1:]
-			continue
 		}
-		if path[idx] == '[' {
-			if idx > 0 {
-				// non-empty
-				exprs = append(exprs, literalField(path[:idx]))
-				path = path[idx:]
-				idx = 0
+	}
+	seg.conds, err = c.parseBraces(mode)
+	if err != nil {
+		return nil, err
+	}
+	seg.end = c.lex.pos
+	return seg, nil
+}
+
+// parseArgs parses method call arguments after '('
+func (c *parser) parseArgs(open token) ([]string, error) {
+	var args []string
+	for {
+		tok, err := c.lex.next(modeArgs)
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokRParen:
+			if len(args) == 0 {
+				return args, nil
+			}
+			return nil, c.errorf(tok.pos, "expecting argument, found %s", tok.kind)
+		case tokString:
+			args = append(args, tok.text)
+		case tokKey:
+			if !isLiteralArg(tok.text) {
+				return nil, c.errorf(tok.pos, "expecting quoted string, number, bool or JSON, found %s", strconv.Quote(tok.text))
+			}
+			args = append(args, tok.text)
+		case tokEOF:
+			return nil, c.errorf(open.pos, "found '(', but missing ')'")
+		default:
+			return nil, c.unexpected(tok)
+		}
+		tok, err = c.lex.next(modeArgs)
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokRParen:
+			return args, nil
+		case tokComma:
+		case tokEOF:
+			return nil, c.errorf(open.pos, "found '(', but missing ')'")
+		default:
+			return nil, c.unexpected(tok)
+		}
+	}
+}
+
+// isLiteralArg tells whether an unquoted argument
+// is a number, a bool, null or a JSON object or array
+func isLiteralArg(s string) bool {
+	if s == "true" || s == "false" || s == "null" {
+		return true
+	}
+	if s[0] == '{' || s[0] == '[' {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// parseBracketSegment parses [x,y,...]{...}
+func (c *parser) parseBracketSegment(mode lexMode) (*segmentNode, error) {
+	open, err := c.lex.next(mode)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segmentNode{kind: segBracket, pos: open.pos}
+	for {
+		tok, err := c.lex.next(modeBracket)
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokKey, tokString:
+			if tok.kind == tokKey && tok.text == "" {
+				return nil, c.errorf(tok.pos, "found empty selector")
 			}
-			// verbatim
-			eidx, err := lookClose(path, idx, "[", "]")
+			seg.selectors = append(seg.selectors, &selectorNode{
+				pos:   tok.pos,
+				text:  tok.text,
+				exact: tok.kind == tokString || tok.escaped,
+			})
+		case tokRBracket, tokComma:
+			return nil, c.errorf(tok.pos, "found empty selector")
+		case tokEOF:
+			return nil, c.errorf(open.pos, "found '[', but missing ']'")
+		default:
+			return nil, c.unexpected(tok)
+		}
+		tok, err = c.lex.next(modeBracket)
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind == tokRBracket {
+			break
+		}
+		if tok.kind == tokEOF {
+			return nil, c.errorf(open.pos, "found '[', but missing ']'")
+		}
+		if tok.kind != tokComma {
+			return nil, c.unexpected(tok)
+		}
+	}
+	seg.conds, err = c.parseBraces(mode)
+	if err != nil {
+		return nil, err
+	}
+	seg.end = c.lex.pos
+	return seg, nil
+}
+
+// parseBraces parses {...}{...}
+func (c *parser) parseBraces(mode lexMode) ([]condNode, error) {
+	var conds []condNode
+	for {
+		tok, err := c.lex.peek(mode)
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind != tokLBrace {
+			return conds, nil
+		}
+		c.lex.next(mode)
+		braceConds, err := c.parseBrace(tok)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, braceConds...)
+	}
+}
+
+// parseBrace parses conditions after '{'
+func (c *parser) parseBrace(open token) ([]condNode, error) {
+	tok, err := c.lex.peek(modeCond)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokRBrace {
+		c.lex.next(modeCond)
+		return nil, nil
+	}
+	cond, err := c.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	tok, err = c.lex.next(modeCond)
+	if err != nil {
+		return nil, err
+	}
+	switch tok.kind {
+	case tokRBrace:
+		return []condNode{cond}, nil
+	case tokEOF:
+		return nil, c.errorf(open.pos, "found '{', but missing '}'")
+	default:
+		return nil, c.unexpected(tok)
+	}
+}
+
+// parseOr parses and || and ...
+func (c *parser) parseOr() (condNode, error) {
+	cond, err := c.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := &orNode{pos: cond.condPos(), conds: []condNode{cond}}
+	for {
+		tok, err := c.lex.peek(modeCond)
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind != tokOr {
+			break
+		}
+		c.lex.next(modeCond)
+		cond, err := c.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or.conds = append(or.conds, cond)
+	}
+	if len(or.conds) == 1 {
+		return or.conds[0], nil
+	}
+	return or, nil
+}
+
+// parseAnd parses unary && unary ..., ',' is same as &&
+func (c *parser) parseAnd() (condNode, error) {
+	cond, err := c.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	and := &andNode{pos: cond.condPos(), conds: []condNode{cond}}
+	for {
+		tok, err := c.lex.peek(modeCond)
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind != tokAnd && tok.kind != tokComma {
+			break
+		}
+		c.lex.next(modeCond)
+		cond, err := c.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and.conds = append(and.conds, cond)
+	}
+	if len(and.conds) == 1 {
+		return and.conds[0], nil
+	}
+	return and, nil
+}
+
+// parseUnary parses !unary, (or), or condition
+func (c *parser) parseUnary() (condNode, error) {
+	tok, err := c.lex.peek(modeCond)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokNot {
+		c.lex.next(modeCond)
+		cond, err := c.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{pos: tok.pos, cond: cond}, nil
+	}
+	if tok.kind == tokLParen {
+		c.lex.next(modeCond)
+		cond, err := c.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		end, err := c.lex.next(modeCond)
+		if err != nil {
+			return nil, err
+		}
+		if end.kind == tokEOF {
+			return nil, c.errorf(tok.pos, "found '(', but missing ')'")
+		}
+		if end.kind != tokRParen {
+			return nil, c.unexpected(end)
+		}
+		return cond, nil
+	}
+	return c.parseCompare()
+}
+
+// parseCompare parses path op value, or path
+func (c *parser) parseCompare() (*compareNode, error) {
+	path, err := c.parsePath(modeCond)
+	if err != nil {
+		return nil, err
+	}
+	node := &compareNode{pos: path.pos, path: path}
+	tok, err := c.lex.peek(modeCond)
+	if err != nil {
+		return nil, err
+	}
+	if len(path.segments) == 0 {
+		return nil, c.errorf(tok.pos, "expecting path, found %s", tok.kind)
+	}
+	if tok.kind != tokOp {
+		return node, nil
+	}
+	c.lex.next(modeCond)
+	node.op = tok.text
+
+	tok, err = c.lex.peek(modeValue)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokKey || tok.kind == tokString {
+		c.lex.next(modeValue)
+		node.value = tok.text
+		// a quoted value must be followed by , or }
+		if tok.kind == tokString {
+			after, err := c.lex.peek(modeValue)
 			if err != nil {
 				return nil, err
 			}
-			vk := path[idx+1 : eidx]
-			if vk == "" {
-				return nil, fmt.Errorf("invalid syntax: found empty '[]' at %v", path)
+			if after.kind == tokKey {
+				return nil, c.unexpected(after)
 			}
-			if false {
-				// TODO: check when to use verbatim
-				exprs = append(exprs, verbatim(vk))
+		}
+	}
+	return node, nil
+}
+
+// compilePath converts AST to exprs
+func compilePath(src string, node *pathNode) ([]pathExpr, error) {
+	exprs, _, err := compileSegments(src, node)
+	return exprs, err
+}
+
+// segmentSpan is the position of a segment in the path
+type segmentSpan struct {
+	pos int
+	end int
+}
+
+// compileSegments converts AST to exprs, spans[i] is
+// the segment of src that exprs[i] is compiled from
+func compileSegments(src string, node *pathNode) (exprs []pathExpr, spans []segmentSpan, err error) {
+	exprs = make([]pathExpr, 0, len(node.segments))
+	spans = make([]segmentSpan, 0, len(node.segments))
+	for _, seg := range node.segments {
+		seg := seg
+		add := func(expr pathExpr) {
+			exprs = append(exprs, expr)
+			spans = append(spans, segmentSpan{pos: seg.pos, end: seg.end})
+		}
+		switch seg.kind {
+		case segDescent:
+			add(recursiveDescent{})
+		case segKey:
+			if seg.call {
+				add(&methodCall{
+					name: seg.key,
+					args: seg.args,
+				})
+				if len(seg.conds) > 0 {
+					cond, err := compileConditions(src, seg.conds)
+					if err != nil {
+						return nil, nil, err
+					}
+					add(&conditionFilter{condition: cond})
+				}
+				continue
+			}
+			if len(seg.conds) == 0 && seg.key != "" && !seg.regex {
+				add(keyExpr(seg.key, seg.exact))
+				continue
+			}
+			var field keyMatcher
+			switch {
+			case seg.regex:
+				re, err := regexp.Compile(seg.key)
+				if err != nil {
+					return nil, nil, newPathSyntaxError(src, seg.pos, "invalid regex: %v", err)
+				}
+				field = &regexKey{re: re}
+			case seg.exact:
+				field = globKey(globQuote(seg.key))
+			case seg.key != "":
+				field = globKey(seg.key)
+			}
+			cond, err := compileConditions(src, seg.conds)
+			if err != nil {
+				return nil, nil, err
+			}
+			add(&variableField{
+				field:     field,
+				condition: cond,
+			})
+		case segParent, segRoot:
+			if seg.kind == segParent {
+				add(parentExpr{})
 			} else {
-				exprs = append(exprs, literalField(vk))
+				add(rootExpr{})
+			}
+			if len(seg.conds) > 0 {
+				cond, err := compileConditions(src, seg.conds)
+				if err != nil {
+					return nil, nil, err
+				}
+				add(&conditionFilter{condition: cond})
 			}
+		case segBracket:
+			selectors := make(union, 0, len(seg.selectors))
+			for _, sel := range seg.selectors {
+				expr, err := compileSelector(src, sel)
+				if err != nil {
+					return nil, nil, err
+				}
+				selectors = append(selectors, expr)
+			}
+			if len(selectors) == 1 {
+				add(selectors[0])
+			} else {
+				add(selectors)
+			}
+			if len(seg.conds) > 0 {
+				cond, err := compileConditions(src, seg.conds)
+				if err != nil {
+					return nil, nil, err
+				}
+				add(&conditionFilter{condition: cond})
+			}
+		default:
+			return nil, nil, newPathSyntaxError(src, seg.pos, "unrecognized segment")
+		}
+	}
+	return exprs, spans, nil
+}
 
-			path = path[eidx+1:]
-			continue
+// compileConditions returns nil if there is no condition
+func compileConditions(src string, nodes []condNode) (condition, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	conds := make(andCondition, 0, len(nodes))
+	for _, node := range nodes {
+		cond, err := compileCondition(src, node)
+		if err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("invalid syntax: unrecognized symbol at %v", path)
+		conds = append(conds, cond)
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
 	}
+	return conds, nil
 }
 
-// parsePairs k1=v1,k2=v2,...
-func parsePairs(s string) map[string]string {
-	m := make(map[string]string)
-	kvs := strings.Split(s, ",")
-	for _, kv := range kvs {
-		kvSplit := strings.SplitN(kv, "=", 2)
-		var k string
-		var v string
-		if len(kvSplit) > 0 {
-			k = kvSplit[0]
+func compileCondition(src string, node condNode) (condition, error) {
+	switch node := node.(type) {
+	case *compareNode:
+		path, err := compilePath(src, node.path)
+		if err != nil {
+			return nil, err
+		}
+		if node.op == "" {
+			return &existCondition{path: path}, nil
 		}
-		if len(kvSplit) > 1 {
-			v = kvSplit[1]
+		op, ok := condOps[node.op]
+		if !ok {
+			return nil, newPathSyntaxError(src, node.pos, "unrecognized operator %s", node.op)
 		}
-		if k == "" {
-			// ignore
+		return &compareCondition{
+			path:  path,
+			op:    op,
+			value: node.value,
+		}, nil
+	case *andNode:
+		conds := make(andCondition, 0, len(node.conds))
+		for _, n := range node.conds {
+			cond, err := compileCondition(src, n)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+		}
+		return conds, nil
+	case *orNode:
+		conds := make(orCondition, 0, len(node.conds))
+		for _, n := range node.conds {
+			cond, err := compileCondition(src, n)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+		}
+		return conds, nil
+	case *notNode:
+		cond, err := compileCondition(src, node.cond)
+		if err != nil {
+			return nil, err
+		}
+		return &notCondition{condition: cond}, nil
+	default:
+		return nil, newPathSyntaxError(src, node.condPos(), "unrecognized condition")
+	}
+}
+
+// compileSelector compiles content inside [] that
+// selects a single child or a slice
+func compileSelector(src string, sel *selectorNode) (pathExpr, error) {
+	if sel.exact {
+		return verbatim(sel.text), nil
+	}
+	s := sel.text
+	if strings.Contains(s, ":") {
+		slice, err := parseSlice(s)
+		if err != nil {
+			return nil, newPathSyntaxError(src, sel.pos, "%v", err)
+		}
+		return slice, nil
+	}
+	if i, err := strconv.Atoi(s); err == nil && i < 0 {
+		return listIndex(i), nil
+	}
+	return literalField(s), nil
+}
+
+// keyExpr returns verbatim for exact keys,
+// which are quoted or escaped
+func keyExpr(key string, exact bool) pathExpr {
+	if exact {
+		return verbatim(key)
+	}
+	return literalField(key)
+}
+
+// parseSlice start:end:step, each part is optional
+func parseSlice(s string) (*listSlice, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("too many ':' in slice '%s'", s)
+	}
+	slice := &listSlice{}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
 			continue
 		}
-		m[k] = v
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("bad slice index '%s'", part)
+		}
+		switch i {
+		case 0:
+			slice.start = &n
+		case 1:
+			slice.end = &n
+		case 2:
+			if n == 0 {
+				return nil, fmt.Errorf("slice step cannot be zero")
+			}
+			slice.step = n
+		}
 	}
-	return m
+	return slice, nil
 }
 
-func lookClose(path string, idx int, open string, close string) (int, error) {
-	endIdx := -1
-	tmpIdx := strings.Index(path[idx+1:], close)
-	if tmpIdx >= 0 {
-		endIdx = tmpIdx + idx + 1
+// globQuote escapes glob meta chars so that
+// globMatch matches s exactly
+func globQuote(s string) string {
+	if !strings.ContainsAny(s, "*?[\\") {
+		return s
 	}
-	if endIdx < 0 {
-		return -1, fmt.Errorf("invalid syntax: found '%s', but missing '%s' at %v", open, close, path)
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
 	}
-	return endIdx, nil
+	return b.String()
 }
@@ -0,0 +1,92 @@
+package objpath
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// go test -run TestParsePathSyntaxError -v ./
+func TestParsePathSyntaxError(t *testing.T) {
+	cases := []struct {
+		path   string
+		offset int
+	}{
+		{"a.b[c", 3},
+		{"a{k=v", 1},
+		{"a...b", 3},
+		{"a.", 2},
+		{"a..", 3},
+		{"a[]", 2},
+		{"a[x,]", 4},
+		{"a]", 1},
+		{"a.}", 2},
+		{"'abc", 0},
+		{"a.'b'c", 5},
+		{"a{=v}", 2},
+		{"a[::0]", 2},
+		{"a{b{c=1}", 1},
+	}
+	for _, c := range cases {
+		_, err := parsePath(c.path)
+		if err == nil {
+			t.Fatalf("%s: expect error", c.path)
+		}
+		syntaxErr, ok := err.(*PathSyntaxError)
+		if !ok {
+			t.Fatalf("%s: expect *PathSyntaxError, actual: %T", c.path, err)
+		}
+		if syntaxErr.Offset != c.offset {
+			t.Fatalf("%s: expect offset %d, actual: %d %v", c.path, c.offset, syntaxErr.Offset, err)
+		}
+	}
+}
+
+// go test -run TestPathSyntaxErrorCaret -v ./
+func TestPathSyntaxErrorCaret(t *testing.T) {
+	_, err := parsePath("a.b[c")
+	s := err.Error()
+	expect := "invalid syntax at offset 3: found '[', but missing ']'\n    a.b[c\n       ^"
+	if s != expect {
+		t.Fatalf("expect %s = %+v, actual:%+v", `s`, expect, s)
+	}
+}
+
+// go test -run TestParsePathNested -v ./
+func TestParsePathNested(t *testing.T) {
+	v := map[string]interface{}{
+		"list": []interface{}{
+			map[string]interface{}{"id": "1", "kind": "a", "meta": map[string]interface{}{"tags": []string{"x", "y"}}},
+			map[string]interface{}{"id": "2", "kind": "a", "meta": map[string]interface{}{"tags": []string{"z"}}},
+			map[string]interface{}{"id": "3", "kind": "b", "meta": map[string]interface{}{"tags": []string{"y"}}},
+		},
+	}
+	cases := map[string]string{
+		"list.*{kind=a}{meta.tags[-1]=y}.id": `[1]`,
+		"list.*{meta{tags.*=z}.tags.0=z}.id": `[2]`,
+		"list[0,2]{kind=b}.id":               `[3]`,
+		"list.{ kind = b }.id":               `[3]`,
+		".list[1].id":                        `[2]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+}
+
+// go test -run TestFilterBadSyntax -v ./
+func TestFilterBadSyntax(t *testing.T) {
+	res := Check(map[string]interface{}{"a": 1}, `{"a[0":"1"}`)
+	if len(res) != 1 {
+		t.Fatalf("expect 1 fail detail, actual: %v", res)
+	}
+	if !strings.Contains(res[0].BadSyntax, "    a[0\n     ^") {
+		t.Fatalf("expect caret in bad syntax, actual: %s", res[0].BadSyntax)
+	}
+}
@@ -0,0 +1,113 @@
+package objpath
+
+import (
+	"fmt"
+)
+
+// Path is a compiled path, it can be
+// used concurrently by multiple goroutines.
+type Path struct {
+	src   string
+	exprs []pathExpr
+	// spans[i] is the segment exprs[i] is compiled from
+	spans []segmentSpan
+}
+
+// QueryError is returned when a segment of the
+// path fails to apply, e.g. a method call fails
+type QueryError struct {
+	Path       string // the queried path
+	Segment    string // the failed segment, e.g. Get("k")
+	ObjectPath string // location of the object the segment applies to, empty for root
+	Cause      error
+}
+
+func (c *QueryError) Error() string {
+	objectPath := c.ObjectPath
+	if objectPath == "" {
+		objectPath = "<root>"
+	}
+	return fmt.Sprintf("query %s: segment %s at %s: %v", c.Path, c.Segment, objectPath, c.Cause)
+}
+
+func (c *QueryError) Unwrap() error {
+	return c.Cause
+}
+
+// CompilePath parses path, on syntax error
+// a *PathSyntaxError is returned
+func CompilePath(path string) (*Path, error) {
+	node, err := parsePathAST(path)
+	if err != nil {
+		return nil, err
+	}
+	exprs, spans, err := compileSegments(path, node)
+	if err != nil {
+		return nil, err
+	}
+	return &Path{
+		src:   path,
+		exprs: exprs,
+		spans: spans,
+	}, nil
+}
+
+// MustCompilePath is like CompilePath but panics on error,
+// it is intended for initializing global paths.
+func MustCompilePath(path string) *Path {
+	p, err := CompilePath(path)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source of the path
+func (c *Path) String() string {
+	return c.src
+}
+
+func (c *Path) Query(v interface{}) ([]Object, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return c.QueryObjects([]Object{NewObject(v)})
+}
+
+func (c *Path) QueryObject(v Object) ([]Object, error) {
+	return c.QueryObjects([]Object{v})
+}
+
+func (c *Path) QueryObjects(v []Object) ([]Object, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	for i, expr := range c.exprs {
+		var err error
+		v, err = expr.Filter(v)
+		if err != nil {
+			var objectPath string
+			if oerr, ok := err.(*objectError); ok {
+				objectPath = pathOf(oerr.obj)
+				err = oerr.err
+			}
+			return nil, &QueryError{
+				Path:       c.src,
+				Segment:    c.src[c.spans[i].pos:c.spans[i].end],
+				ObjectPath: objectPath,
+				Cause:      err,
+			}
+		}
+		if len(v) == 0 {
+			return nil, nil
+		}
+	}
+	return v, nil
+}
+
+// pathOf returns the location of obj from
+// the top level object, e.g. items.3
+func pathOf(obj Object) string {
+	keys, _ := LocationOf(RootOf(obj), obj)
+	return JoinPath(keys)
+}
@@ -2,9 +2,11 @@ package objpath
 
 import (
 	"fmt"
-	"path/filepath"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type pathExpr interface {
@@ -12,33 +14,136 @@ type pathExpr interface {
 	// ${prefix}.${field}
 	// or ${prefix}.*{}
 	// Filter filter and navigate through candidates
-	Filter(candidates []Object) []Object
+	Filter(candidates []Object) ([]Object, error)
 }
 type literalField string
 type verbatim string
 
 type variableField struct {
-	field     string // empty for any
-	condition map[string]string
+	field     keyMatcher // nil for any
+	condition condition  // nil for always
 }
 
-func (c literalField) Filter(objects []Object) []Object {
+// keyMatcher matches keys of children
+type keyMatcher interface {
+	matchKey(key string) bool
+}
+
+// globKey matches keys by glob, e.g. a*
+type globKey string
+
+// regexKey matches keys by regular expression, e.g. ~/^a\d+$/
+type regexKey struct {
+	re *regexp.Regexp
+}
+
+func (c globKey) matchKey(key string) bool {
+	return globMatch(key, string(c))
+}
+
+func (c *regexKey) matchKey(key string) bool {
+	return c.re.MatchString(key)
+}
+
+// conditionFilter keeps candidates that
+// satisfy the condition, e.g. a[0]{k=v}
+type conditionFilter struct {
+	condition condition
+}
+
+// condition is the content of {} that
+// tests an object
+type condition interface {
+	match(obj Object) (bool, error)
+}
+
+// andCondition holds when all holds,
+// k1=v1,k2=v2 or {k1=v1}{k2=v2}
+type andCondition []condition
+
+// orCondition holds when any holds,
+// k1=v1 || k2=v2
+type orCondition []condition
+
+// compareCondition path op value, holds when any
+// primitive found by path satisfies op against value
+type compareCondition struct {
+	path  []pathExpr
+	op    Op
+	value string
+}
+
+// existCondition holds when path finds anything not null
+type existCondition struct {
+	path []pathExpr
+}
+
+// notCondition !cond
+type notCondition struct {
+	condition condition
+}
+
+// listIndex selects a List element by index,
+// negative index counts from the end
+type listIndex int
+
+// listSlice selects List elements in [start,end) by step,
+// like python's slice
+type listSlice struct {
+	start *int
+	end   *int
+	step  int // 0 means 1
+}
+
+// union selects children by each of its
+// selectors in order
+type union []pathExpr
+
+// methodCall calls method with args, e.g. Get("k")
+type methodCall struct {
+	name string
+	args []string
+}
+
+// recursiveDescent expands each candidate to itself and
+// all of its descendant Composites, so the following
+// expr matches at any depth, Refs are not expanded
+type recursiveDescent struct{}
+
+// parentExpr ^ selects the parent of each candidate
+type parentExpr struct{}
+
+// rootExpr $ selects the top level object
+// that each candidate is found from
+type rootExpr struct{}
+
+func (c literalField) Filter(objects []Object) ([]Object, error) {
 	var res []Object
 	s := string(c)
 	hasWildcard := strings.Contains(s, "*")
+	prop, isPseudo := lookupPseudoProperty(s)
 
 	for _, obj := range objects {
 		if obj == nil {
 			continue
 		}
-		switch obj := obj.(type) {
-		case Primitive:
-			if c == "$length" {
-				length := len(obj.StrValue())
-				n := strconv.FormatInt(int64(length), 10)
-				res = append(res, NewPrimitve(length, n))
-				continue
+		if isPseudo {
+			// real children like {"$type":"x"} take precedence
+			if comp, ok := obj.(Composite); ok {
+				if v, ok := comp.GetChild(s); ok {
+					res = append(res, v)
+					continue
+				}
+			}
+			v, ok := prop(obj)
+			if ok {
+				res = append(res, locate(v, obj, s))
 			}
+			continue
+		}
+		switch obj := obj.(type) {
+		case Primitive, *Null:
+			// ignore
 		case Composite:
 			if c == "*" {
 				// a special version of *{}
@@ -47,11 +152,6 @@ func (c literalField) Filter(objects []Object) []Object {
 					return true
 				})
 				continue
-			} else if c == "$length" {
-				childLen := obj.ChildrenLen()
-				n := strconv.FormatInt(int64(childLen), 10)
-				res = append(res, NewPrimitve(childLen, n))
-				continue
 			}
 			if !hasWildcard {
 				v, ok := obj.GetChild(s)
@@ -61,18 +161,15 @@ func (c literalField) Filter(objects []Object) []Object {
 					if !ok {
 						continue
 					}
-					methRes, err := CallFn(meth, nil)
+					methRes, ok, err := callMethod(meth, s, nil)
 					if err != nil {
-						panic(fmt.Errorf("call %s: %v", s, err))
+						return nil, errorAt(obj, err)
 					}
-					if len(methRes) == 0 {
+					if !ok {
 						// no return
 						continue
 					}
-					if len(methRes) > 1 {
-						panic(fmt.Errorf("call %s returns more than 1 result:%d", s, len(methRes)))
-					}
-					res = append(res, NewObject(methRes[0]))
+					res = append(res, locate(newObjectFrom(obj, methRes), obj, s))
 					continue
 				}
 
@@ -86,20 +183,20 @@ func (c literalField) Filter(objects []Object) []Object {
 				})
 			}
 		default:
-			panic(fmt.Errorf("unhandled obj:%T", obj))
+			return nil, unhandledObject(obj)
 		}
 	}
 
-	return res
+	return res, nil
 }
-func (c verbatim) Filter(objects []Object) []Object {
+func (c verbatim) Filter(objects []Object) ([]Object, error) {
 	var res []Object
 	for _, obj := range objects {
 		if obj == nil {
 			continue
 		}
 		switch obj := obj.(type) {
-		case Primitive:
+		case Primitive, *Null:
 			// ignore
 		case Composite:
 			v, ok := obj.GetChild(string(c))
@@ -108,135 +205,513 @@ func (c verbatim) Filter(objects []Object) []Object {
 			}
 			res = append(res, v)
 		default:
-			panic(fmt.Errorf("unhandled obj:%T", obj))
+			return nil, unhandledObject(obj)
+		}
+	}
+	return res, nil
+}
+
+func (c recursiveDescent) Filter(objects []Object) ([]Object, error) {
+	var res []Object
+	var walk func(obj Composite)
+	walk = func(obj Composite) {
+		res = append(res, obj)
+		obj.RangeChildren(func(key string, child Object) bool {
+			if comp, ok := child.(Composite); ok && !IsRef(child) {
+				walk(comp)
+			}
+			return true
+		})
+	}
+	for _, obj := range objects {
+		if obj == nil {
+			continue
+		}
+		switch obj := obj.(type) {
+		case Primitive, *Null:
+			// ignore
+		case Composite:
+			walk(obj)
+		default:
+			return nil, unhandledObject(obj)
+		}
+	}
+	return res, nil
+}
+
+func (c listIndex) Filter(objects []Object) ([]Object, error) {
+	var res []Object
+	for _, obj := range objects {
+		list, ok := asList(obj)
+		if !ok {
+			continue
+		}
+		children := list.getChildren()
+		i := int(c)
+		if i < 0 {
+			i += len(children)
+		}
+		if i < 0 || i >= len(children) {
+			continue
+		}
+		res = append(res, children[i])
+	}
+	return res, nil
+}
+
+func (c *listSlice) Filter(objects []Object) ([]Object, error) {
+	var res []Object
+	for _, obj := range objects {
+		list, ok := asList(obj)
+		if !ok {
+			continue
+		}
+		children := list.getChildren()
+		start, end, step := c.bounds(len(children))
+		if step > 0 {
+			for i := start; i < end; i += step {
+				res = append(res, children[i])
+			}
+		} else {
+			for i := start; i > end; i += step {
+				res = append(res, children[i])
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c *listSlice) String() string {
+	var b strings.Builder
+	if c.start != nil {
+		b.WriteString(strconv.Itoa(*c.start))
+	}
+	b.WriteString(":")
+	if c.end != nil {
+		b.WriteString(strconv.Itoa(*c.end))
+	}
+	if c.step != 0 {
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(c.step))
+	}
+	return b.String()
+}
+
+// bounds normalizes start and end against n
+func (c *listSlice) bounds(n int) (start int, end int, step int) {
+	step = c.step
+	if step == 0 {
+		step = 1
+	}
+	normalize := func(i int, lower int, upper int) int {
+		if i < 0 {
+			i += n
+		}
+		if i < lower {
+			return lower
+		}
+		if i > upper {
+			return upper
+		}
+		return i
+	}
+	if step > 0 {
+		start, end = 0, n
+		if c.start != nil {
+			start = normalize(*c.start, 0, n)
+		}
+		if c.end != nil {
+			end = normalize(*c.end, 0, n)
+		}
+	} else {
+		start, end = n-1, -1
+		if c.start != nil {
+			start = normalize(*c.start, -1, n-1)
+		}
+		if c.end != nil {
+			end = normalize(*c.end, -1, n-1)
+		}
+	}
+	return
+}
+
+func (c union) Filter(objects []Object) ([]Object, error) {
+	var res []Object
+	for _, obj := range objects {
+		if obj == nil {
+			continue
+		}
+		single := []Object{obj}
+		for _, selector := range c {
+			children, err := selector.Filter(single)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, children...)
+		}
+	}
+	return res, nil
+}
+
+func (c *methodCall) Filter(objects []Object) ([]Object, error) {
+	var res []Object
+	for _, obj := range objects {
+		if obj == nil {
+			continue
+		}
+		meth, ok := obj.Method(c.name)
+		if !ok {
+			continue
+		}
+		v, ok, err := callMethod(meth, c.name, c.args)
+		if err != nil {
+			return nil, errorAt(obj, err)
+		}
+		if !ok {
+			continue
+		}
+		res = append(res, locate(newObjectFrom(obj, v), obj, c.name))
+	}
+	return res, nil
+}
+
+func (c parentExpr) Filter(objects []Object) ([]Object, error) {
+	var res []Object
+	seen := make(map[Object]bool)
+	for _, obj := range objects {
+		if obj == nil {
+			continue
+		}
+		res = appendUnique(res, seen, ParentOf(obj))
+	}
+	return res, nil
+}
+
+func (c rootExpr) Filter(objects []Object) ([]Object, error) {
+	var res []Object
+	seen := make(map[Object]bool)
+	for _, obj := range objects {
+		if obj == nil {
+			continue
 		}
+		res = appendUnique(res, seen, RootOf(obj))
 	}
-	return res
+	return res, nil
 }
 
-// TODO: make it util
-func prefixedOrEquals(s string, prefix string, split string) (next string, ok bool) {
-	hasPrefix := strings.HasPrefix(s, prefix)
-	if !hasPrefix {
-		return "", false
+// appendUnique appends obj to res if it is not seen,
+// siblings share the same parent, so a.*.^ gives a once
+func appendUnique(res []Object, seen map[Object]bool, obj Object) []Object {
+	if obj == nil {
+		return res
 	}
-	if len(s) == len(prefix) {
-		return "", true
+	if !reflect.TypeOf(obj).Comparable() {
+		return append(res, obj)
 	}
-	suffix := s[len(prefix):]
-	if split == "" {
-		return suffix, true
+	if seen[obj] {
+		return res
 	}
-	if !strings.HasPrefix(suffix, split) {
-		return "", false
+	seen[obj] = true
+	return append(res, obj)
+}
+
+// locate sets the location of obj computed from parent,
+// like method call results, so that ^ goes back to parent.
+// Objects already Located are kept as is, nil is a Null.
+func locate(obj Object, parent Object, key string) Object {
+	if obj == nil {
+		obj = NewNull(nil)
 	}
-	return suffix[len(split):], true
+	if obj == parent || ParentOf(obj) != nil {
+		return obj
+	}
+	if l, ok := obj.(locatable); ok {
+		l.setLocation(parent, key)
+	}
+	return obj
 }
 
-func (c *variableField) Filter(objects []Object) []Object {
+func unhandledObject(obj Object) error {
+	return errorAt(obj, fmt.Errorf("unhandled object type:%T", obj))
+}
+
+// objectError is an error of applying a segment to obj
+type objectError struct {
+	obj Object
+	err error
+}
+
+func (c *objectError) Error() string {
+	return c.err.Error()
+}
+
+func (c *objectError) Unwrap() error {
+	return c.err
+}
+
+// errorAt attaches obj to err, errors of nested
+// paths in conditions keep their deeper object
+func errorAt(obj Object, err error) error {
+	if _, ok := err.(*objectError); ok {
+		return err
+	}
+	return &objectError{obj: obj, err: err}
+}
+
+// callMethod calls meth with args, ok is false if
+// meth returns nothing, a panic is returned as error
+func callMethod(meth interface{}, name string, args []string) (v interface{}, ok bool, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("call %s: panic: %v", name, e)
+		}
+	}()
+	methRes, err := CallFn(meth, args)
+	if err != nil {
+		return nil, false, fmt.Errorf("call %s: %v", name, err)
+	}
+	if len(methRes) == 0 {
+		// no return
+		return nil, false, nil
+	}
+	if len(methRes) > 1 {
+		return nil, false, fmt.Errorf("call %s returns more than 1 result:%d", name, len(methRes))
+	}
+	return methRes[0], true, nil
+}
+
+func (c *variableField) Filter(objects []Object) ([]Object, error) {
 	res := make([]Object, 0)
 	for _, obj := range objects {
 		if obj == nil {
 			continue
 		}
 		switch obj := obj.(type) {
-		case Primitive:
+		case Primitive, *Null:
 			// ignore
 		case Composite:
+			var err error
 			obj.RangeChildren(func(key string, child Object) bool {
-				if !globMatch(key, c.field) {
+				if c.field != nil && !c.field.matchKey(key) {
 					return true
 				}
-
-				if len(c.condition) == 0 {
+				if c.condition == nil {
 					res = append(res, child)
 					return true
 				}
-
-				ch := []Object{child}
-				for k, v := range c.condition {
-					var err error
-					ch, err = QueryObjects(ch, k)
-					if err != nil {
-						// invalid path, skip
-						break
-					}
-					if len(ch) == 0 {
-						break
-					}
-					j := 0
-					for i := 0; i < len(ch); i++ {
-						prim, ok := ch[i].(Primitive)
-						if !ok {
-							continue
-						}
-						if prim.StrValue() == v {
-							ch[j] = ch[i]
-							j++
-						}
-					}
-					ch = ch[:j]
-					if len(ch) == 0 {
-						break
-					}
+				var ok bool
+				ok, err = c.condition.match(child)
+				if err != nil {
+					return false
 				}
-				if len(ch) > 0 {
+				if ok {
 					res = append(res, child)
 				}
 				return true
 			})
+			if err != nil {
+				return nil, err
+			}
 		default:
-			panic(fmt.Errorf("unhandled obj:%T", obj))
+			return nil, unhandledObject(obj)
+		}
+	}
+	return res, nil
+}
+
+func (c *conditionFilter) Filter(objects []Object) ([]Object, error) {
+	res := make([]Object, 0, len(objects))
+	for _, obj := range objects {
+		if obj == nil {
+			continue
+		}
+		ok, err := c.condition.match(obj)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, obj)
 		}
 	}
-	return res
+	return res, nil
 }
 
-func debugString(p pathExpr) string {
-	switch p := p.(type) {
-	case literalField:
-		return string(p)
-	case verbatim:
-		return "verbatim:" + string(p)
-	case *variableField:
-		return fmt.Sprintf("condition:%v", p.field)
-	default:
-		panic(fmt.Errorf("unrecognized type:%T", p))
+func (c andCondition) match(obj Object) (bool, error) {
+	for _, cond := range c {
+		ok, err := cond.match(obj)
+		if err != nil || !ok {
+			return false, err
+		}
 	}
+	return true, nil
 }
 
-// g(P,s):
-//    if P not startsWith *, then P[:i]==s[:i] && g(P[i:],s[i:])
-//    else g(P,s[1:]) or g(P[1:],s)
-func globMatch(key string, glob string) bool {
-	if glob == "" {
-		return key == ""
+func (c orCondition) match(obj Object) (bool, error) {
+	for _, cond := range c {
+		ok, err := cond.match(obj)
+		if err != nil || ok {
+			return ok, err
+		}
 	}
+	return false, nil
+}
 
-	if true {
-		matched, _ := filepath.Match(glob, key)
-		return matched
+func (c *compareCondition) match(obj Object) (bool, error) {
+	objs, err := filterAll(c.path, obj)
+	if err != nil {
+		return false, err
+	}
+	for _, o := range objs {
+		prim, ok := o.(Primitive)
+		if !ok {
+			continue
+		}
+		if c.op.CheckPrimitive(prim, c.value) {
+			return true, nil
+		}
 	}
+	return false, nil
+}
 
-	if glob == "*" {
-		return true
+func (c *existCondition) match(obj Object) (bool, error) {
+	objs, err := filterAll(c.path, obj)
+	if err != nil {
+		return false, err
 	}
+	for _, o := range objs {
+		if !IsNull(o) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-	strings.SplitAfter(glob, "*")
+func (c *notCondition) match(obj Object) (bool, error) {
+	ok, err := c.condition.match(obj)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
 
-	prefixGlob := strings.HasPrefix(glob, "*")
-	suffixGlob := strings.HasSuffix(glob, "*")
+// filterAll applies exprs to obj in order
+func filterAll(exprs []pathExpr, obj Object) ([]Object, error) {
+	res := []Object{obj}
+	for _, expr := range exprs {
+		var err error
+		res, err = expr.Filter(res)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) == 0 {
+			return nil, nil
+		}
+	}
+	return res, nil
+}
 
-	str := strings.TrimPrefix(glob, "*")
-	str = strings.TrimSuffix(str, "*")
-	if prefixGlob && suffixGlob {
-		return strings.Contains(key, str)
+// globMatch matches key against glob like filepath.Match:
+// * matches any chars, ? matches one char, [a-z] and [^a-z]
+// match one char in or not in the class, \ escapes the
+// following char. Unlike filepath.Match, '/' is not special.
+//
+// g(P,s):
+//    if P not startsWith *, then P[:i]==s[:i] && g(P[i:],s[i:])
+//    else g(P,s[1:]) or g(P[1:],s)
+// only the last * needs to be retried.
+func globMatch(key string, glob string) bool {
+	px, kx := 0, 0
+	// where to retry if the last * matches one more char
+	starPx, starKx := -1, -1
+	for px < len(glob) || kx < len(key) {
+		if px < len(glob) {
+			if glob[px] == '*' {
+				starPx, starKx = px, kx
+				px++
+				continue
+			}
+			if kx < len(key) {
+				plen, klen, ok, valid := globMatchChar(glob[px:], key[kx:])
+				if !valid {
+					return false
+				}
+				if ok {
+					px += plen
+					kx += klen
+					continue
+				}
+			}
+		}
+		if starPx < 0 || starKx >= len(key) {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(key[starKx:])
+		starKx += size
+		px, kx = starPx+1, starKx
 	}
-	if prefixGlob {
-		return strings.HasSuffix(key, str)
+	return true
+}
+
+// globMatchChar matches the first char of key against the first
+// char or class of glob, plen and klen are bytes consumed
+func globMatchChar(glob string, key string) (plen int, klen int, ok bool, valid bool) {
+	r, klen := utf8.DecodeRuneInString(key)
+	switch glob[0] {
+	case '?':
+		return 1, klen, true, true
+	case '\\':
+		if len(glob) < 2 {
+			return 0, 0, false, false
+		}
+		pr, size := utf8.DecodeRuneInString(glob[1:])
+		return 1 + size, klen, pr == r, true
+	case '[':
+		i := 1
+		negate := i < len(glob) && glob[i] == '^'
+		if negate {
+			i++
+		}
+		matched := false
+		for first := true; ; first = false {
+			if i >= len(glob) {
+				return 0, 0, false, false
+			}
+			if glob[i] == ']' && !first {
+				i++
+				break
+			}
+			lo, size, ok := globClassChar(glob[i:])
+			if !ok {
+				return 0, 0, false, false
+			}
+			i += size
+			hi := lo
+			if i+1 < len(glob) && glob[i] == '-' && glob[i+1] != ']' {
+				hi, size, ok = globClassChar(glob[i+1:])
+				if !ok {
+					return 0, 0, false, false
+				}
+				i += 1 + size
+			}
+			if lo <= r && r <= hi {
+				matched = true
+			}
+		}
+		return i, klen, matched != negate, true
 	}
-	if suffixGlob {
-		return strings.HasPrefix(key, str)
+	pr, size := utf8.DecodeRuneInString(glob)
+	return size, klen, pr == r, true
+}
+
+// globClassChar reads a possibly escaped char inside []
+func globClassChar(glob string) (r rune, size int, ok bool) {
+	if glob[0] == '\\' {
+		if len(glob) < 2 {
+			return 0, 0, false
+		}
+		r, size = utf8.DecodeRuneInString(glob[1:])
+		return r, 1 + size, true
 	}
-	return key == str
+	r, size = utf8.DecodeRuneInString(glob)
+	return r, size, true
 }
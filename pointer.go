@@ -0,0 +1,364 @@
+package objpath
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrPointerNotFound is returned when a JSON Pointer
+// refers to nothing
+var ErrPointerNotFound = errors.New("not found")
+
+// ParsePointer splits a RFC 6901 JSON Pointer into
+// unescaped tokens, e.g. /a~1b/0 gives [a/b 0].
+// The empty pointer refers to the whole document.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %q: must start with '/'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		if !strings.Contains(token, "~") {
+			continue
+		}
+		var b strings.Builder
+		for j := 0; j < len(token); j++ {
+			if token[j] != '~' {
+				b.WriteByte(token[j])
+				continue
+			}
+			if j+1 >= len(token) || (token[j+1] != '0' && token[j+1] != '1') {
+				return nil, fmt.Errorf("invalid json pointer %q: '~' must be followed by 0 or 1", pointer)
+			}
+			j++
+			if token[j] == '0' {
+				b.WriteByte('~')
+			} else {
+				b.WriteByte('/')
+			}
+		}
+		tokens[i] = b.String()
+	}
+	return tokens, nil
+}
+
+// FormatPointer joins tokens into a JSON Pointer,
+// escaping '~' and '/'
+func FormatPointer(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		token = strings.ReplaceAll(token, "~", "~0")
+		token = strings.ReplaceAll(token, "/", "~1")
+		b.WriteString(token)
+	}
+	return b.String()
+}
+
+// QueryPointer resolves the JSON Pointer against v, like
+// Query, nothing is returned if not found.
+func QueryPointer(v interface{}, pointer string) ([]Object, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return QueryPointerObject(NewObject(v), pointer)
+}
+
+// QueryPointerObject is QueryPointer against an Object
+func QueryPointerObject(obj Object, pointer string) ([]Object, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		comp, ok := obj.(Composite)
+		if !ok {
+			return nil, nil
+		}
+		if _, isList := asList(comp); isList && !isPointerIndex(token) {
+			// leading zeros and "-" are not indices
+			return nil, nil
+		}
+		obj, ok = comp.GetChild(token)
+		if !ok {
+			return nil, nil
+		}
+	}
+	return []Object{obj}, nil
+}
+
+// GetPointer is like QueryPointer but reports ErrPointerNotFound
+// if the pointer refers to nothing. The Object is a Null for null.
+func GetPointer(v interface{}, pointer string) (Object, error) {
+	objs, err := QueryPointer(v, pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("json pointer %s: %w", pointer, ErrPointerNotFound)
+	}
+	return objs[0], nil
+}
+
+// isPointerIndex tells whether token is an array index: 0 or [1-9][0-9]*
+func isPointerIndex(token string) bool {
+	if token == "" || (token[0] == '0' && len(token) > 1) {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		if !isDigit(token[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetPointer sets the value referred by pointer inside v,
+// v must be a non-nil pointer or map. Map entries are
+// added if missing, "-" appends to a slice. value must be
+// assignable or convertible to the target type.
+func SetPointer(v interface{}, pointer string, value interface{}) error {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Ptr && !rv.IsNil():
+		elem := rv.Elem()
+		newElem, err := setPointer(elem, tokens, value)
+		if err != nil {
+			return fmt.Errorf("json pointer %s: %w", pointer, err)
+		}
+		elem.Set(newElem)
+		return nil
+	case rv.Kind() == reflect.Map && !rv.IsNil() && len(tokens) > 0:
+		_, err := setPointer(rv, tokens, value)
+		if err != nil {
+			return fmt.Errorf("json pointer %s: %w", pointer, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("json pointer %s: cannot set in %T, expecting a non-nil pointer", pointer, v)
+}
+
+// setPointer returns rv with tokens set to value, the caller
+// stores it back since rv may not be addressable
+func setPointer(rv reflect.Value, tokens []string, value interface{}) (reflect.Value, error) {
+	if len(tokens) == 0 {
+		return convertValue(value, rv.Type())
+	}
+	token := tokens[0]
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return rv, fmt.Errorf("%s: nil pointer: %w", token, ErrPointerNotFound)
+		}
+		elem := rv.Elem()
+		newElem, err := setPointer(elem, tokens, value)
+		if err != nil {
+			return rv, err
+		}
+		elem.Set(newElem)
+		return rv, nil
+	case reflect.Interface:
+		if rv.IsNil() {
+			return rv, fmt.Errorf("%s: nil: %w", token, ErrPointerNotFound)
+		}
+		elem := reflect.New(rv.Elem().Type()).Elem()
+		elem.Set(rv.Elem())
+		newElem, err := setPointer(elem, tokens, value)
+		if err != nil {
+			return rv, err
+		}
+		iv := reflect.New(rv.Type()).Elem()
+		iv.Set(newElem)
+		return iv, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return rv, fmt.Errorf("%s: nil map: %w", token, ErrPointerNotFound)
+		}
+		key, err := convertKey(token, rv.Type().Key())
+		if err != nil {
+			return rv, err
+		}
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		if old := rv.MapIndex(key); old.IsValid() {
+			elem.Set(old)
+		} else if len(tokens) > 1 {
+			return rv, fmt.Errorf("%s: %w", token, ErrPointerNotFound)
+		}
+		newElem, err := setPointer(elem, tokens[1:], value)
+		if err != nil {
+			return rv, err
+		}
+		rv.SetMapIndex(key, newElem)
+		return rv, nil
+	case reflect.Slice, reflect.Array:
+		if token == "-" && rv.Kind() == reflect.Slice && len(tokens) == 1 {
+			elem, err := convertValue(value, rv.Type().Elem())
+			if err != nil {
+				return rv, err
+			}
+			return reflect.Append(rv, elem), nil
+		}
+		i, err := strconv.Atoi(token)
+		if !isPointerIndex(token) || err != nil || i >= rv.Len() {
+			return rv, fmt.Errorf("%s: %w", token, ErrPointerNotFound)
+		}
+		if rv.Kind() == reflect.Array && !rv.CanAddr() {
+			cp := reflect.New(rv.Type()).Elem()
+			cp.Set(rv)
+			rv = cp
+		}
+		elem := rv.Index(i)
+		newElem, err := setPointer(elem, tokens[1:], value)
+		if err != nil {
+			return rv, err
+		}
+		elem.Set(newElem)
+		return rv, nil
+	case reflect.Struct:
+		field, ok := rv.Type().FieldByName(token)
+		if !ok || field.PkgPath != "" {
+			return rv, fmt.Errorf("%s: %w", token, ErrPointerNotFound)
+		}
+		if !rv.CanAddr() {
+			cp := reflect.New(rv.Type()).Elem()
+			cp.Set(rv)
+			rv = cp
+		}
+		fv, err := fieldByIndex(rv, field.Index)
+		if err != nil {
+			return rv, fmt.Errorf("%s: %w", token, err)
+		}
+		newField, err := setPointer(fv, tokens[1:], value)
+		if err != nil {
+			return rv, err
+		}
+		fv.Set(newField)
+		return rv, nil
+	}
+	return rv, fmt.Errorf("%s: %s has no children: %w", token, rv.Type(), ErrPointerNotFound)
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex without
+// panicking on nil embedded pointers
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return rv, fmt.Errorf("nil embedded %s: %w", rv.Type(), ErrPointerNotFound)
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, nil
+}
+
+// convertKey converts a pointer token to map key
+func convertKey(token string, keyType reflect.Type) (reflect.Value, error) {
+	key, err := createArg(token, keyType)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("map key %s: %w", token, err)
+	}
+	return key, nil
+}
+
+// convertValue converts value to typ for setting
+func convertValue(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		switch typ.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			return reflect.Zero(typ), nil
+		}
+		return reflect.Value{}, fmt.Errorf("cannot set null to %s", typ)
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Type().AssignableTo(typ) {
+		return rv, nil
+	}
+	if rv.Type().ConvertibleTo(typ) && sameKindFamily(rv.Kind(), typ.Kind()) {
+		return rv.Convert(typ), nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot set %T to %s", value, typ)
+}
+
+// sameKindFamily prevents conversions like int to string
+func sameKindFamily(a reflect.Kind, b reflect.Kind) bool {
+	family := func(k reflect.Kind) int {
+		switch k {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+			return 1
+		case reflect.String:
+			return 2
+		default:
+			return 3 + int(k)
+		}
+	}
+	return family(a) == family(b)
+}
+
+// PathToPointer converts a path of plain keys and indices,
+// like a.b[0] or $.a.'x/y', to JSON Pointer /a/b/0, paths
+// with wildcards, conditions or method calls are rejected.
+func PathToPointer(path string) (string, error) {
+	node, err := parsePathAST(path)
+	if err != nil {
+		return "", err
+	}
+	tokens := make([]string, 0, len(node.segments))
+	for i, seg := range node.segments {
+		switch seg.kind {
+		case segRoot:
+			if i == 0 && len(seg.conds) == 0 {
+				continue
+			}
+		case segKey:
+			if seg.call || seg.regex || len(seg.conds) > 0 || seg.key == "" {
+				break
+			}
+			if !seg.exact {
+				if strings.Contains(seg.key, "*") {
+					break
+				}
+				if _, ok := lookupPseudoProperty(seg.key); ok {
+					break
+				}
+			}
+			tokens = append(tokens, seg.key)
+			continue
+		case segBracket:
+			if len(seg.selectors) != 1 || len(seg.conds) > 0 {
+				break
+			}
+			sel := seg.selectors[0]
+			if !sel.exact && !isPointerIndex(sel.text) {
+				break
+			}
+			tokens = append(tokens, sel.text)
+			continue
+		}
+		return "", newPathSyntaxError(path, seg.pos, "cannot convert %s to json pointer", path[seg.pos:seg.end])
+	}
+	return FormatPointer(tokens), nil
+}
+
+// PointerToPath converts a JSON Pointer to path,
+// keys are quoted if needed: /a/b.c/0 gives a.'b.c'.0
+func PointerToPath(pointer string) (string, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+	return JoinPath(tokens), nil
+}
@@ -0,0 +1,164 @@
+package objpath
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// go test -run TestQueryPointer -v ./
+func TestQueryPointer(t *testing.T) {
+	// examples of RFC 6901
+	v := map[string]interface{}{
+		"foo":   []string{"bar", "baz"},
+		"":      0,
+		"a/b":   1,
+		"c%d":   2,
+		"e^f":   3,
+		"g|h":   4,
+		"i\\j":  5,
+		"k\"l":  6,
+		" ":     7,
+		"m~n":   8,
+		"items": []testUser{{First: "Ada"}},
+	}
+	cases := map[string]string{
+		`/foo`:           `[[bar baz]]`,
+		`/foo/0`:         `[bar]`,
+		`/`:              `[0]`,
+		`/a~1b`:          `[1]`,
+		`/c%d`:           `[2]`,
+		`/e^f`:           `[3]`,
+		`/g|h`:           `[4]`,
+		`/i\j`:           `[5]`,
+		`/k"l`:           `[6]`,
+		`/ `:             `[7]`,
+		`/m~0n`:          `[8]`,
+		`/items/0/First`: `[Ada]`,
+		`/foo/2`:         `[]`,
+		`/foo/-`:         `[]`,
+		`/foo/01`:        `[]`,
+		`/foo/0/x`:       `[]`,
+		`/missing`:       `[]`,
+	}
+	for ptr, expect := range cases {
+		res, err := QueryPointer(v, ptr)
+		if err != nil {
+			t.Fatalf("%s: %v", ptr, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", ptr, `s`, expect, s)
+		}
+	}
+
+	obj, err := GetPointer(v, "")
+	if err != nil || obj.Value() == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", `GetPointer(v, "")`, v, err)
+	}
+	_, err = GetPointer(v, "/missing")
+	if !errors.Is(err, ErrPointerNotFound) {
+		t.Fatalf("expect %s = %+v, actual:%+v", `err`, ErrPointerNotFound, err)
+	}
+	for _, ptr := range []string{"foo", "/m~2n", "/m~"} {
+		_, err := QueryPointer(v, ptr)
+		if err == nil {
+			t.Fatalf("%s: expect error", ptr)
+		}
+	}
+}
+
+// go test -run TestSetPointer -v ./
+func TestSetPointer(t *testing.T) {
+	type Item struct {
+		Price int
+		Tags  []string
+	}
+	type Order struct {
+		Items []Item
+		Meta  map[string]interface{}
+		Arr   [2]int
+	}
+	order := &Order{
+		Items: []Item{{Price: 1}},
+		Meta:  map[string]interface{}{"n": map[string]interface{}{"x": 1}},
+	}
+	sets := []struct {
+		ptr   string
+		value interface{}
+	}{
+		{"/Items/0/Price", 10},
+		{"/Items/0/Tags/-", "a"},
+		{"/Items/-", Item{Price: 2}},
+		{"/Meta/k", "v"},
+		{"/Meta/n/x", 2.5},
+		{"/Arr/1", int64(3)},
+	}
+	for _, s := range sets {
+		if err := SetPointer(order, s.ptr, s.value); err != nil {
+			t.Fatalf("%s: %v", s.ptr, err)
+		}
+	}
+	AssertT(t, order, `{
+		"Items.0.Price":"10",
+		"Items.0.Tags.0":"a",
+		"Items.1.Price":"2",
+		"Meta.k":"v",
+		"Meta.n.x":"2.5",
+		"Arr.1":"3"
+	}`)
+
+	errCases := map[string]interface{}{
+		"/Items/5/Price":   1,
+		"/Items/0/Price":   "x",
+		"/Missing":         1,
+		"/Meta/none/x":     1,
+		"/Items/0/Price/x": 1,
+	}
+	for ptr, value := range errCases {
+		if err := SetPointer(order, ptr, value); err == nil {
+			t.Fatalf("%s: expect error", ptr)
+		}
+	}
+	if err := SetPointer(*order, "/Meta/k", "v"); err == nil {
+		t.Fatalf("expect error setting in non pointer")
+	}
+}
+
+// go test -run TestPathPointerConvert -v ./
+func TestPathPointerConvert(t *testing.T) {
+	cases := map[string]string{
+		`a.b.0`:         `/a/b/0`,
+		`a[0].b`:        `/a/0/b`,
+		`$.a`:           `/a`,
+		`a.'x/y'.'m~n'`: `/a/x~1y/m~0n`,
+		`a["b.c"]`:      `/a/b.c`,
+	}
+	for path, expect := range cases {
+		ptr, err := PathToPointer(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if ptr != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `ptr`, expect, ptr)
+		}
+	}
+	for _, path := range []string{`a.*`, `a{b=1}`, `a..b`, `a[-1]`, `a[0:2]`, `a.$length`, `a.F()`, `a.^`} {
+		if _, err := PathToPointer(path); err == nil {
+			t.Fatalf("%s: expect error", path)
+		}
+	}
+
+	path, err := PointerToPath("/a/b.c/0/m~0n~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != `a.'b.c'.0.m~n/` {
+		t.Fatalf("expect %s = %+v, actual:%+v", `path`, `a.'b.c'.0.m~n/`, path)
+	}
+
+	res := Check(map[string]interface{}{"orders": []interface{}{map[string]interface{}{"price": 3}}}, `{"orders.*.price":{"$gt":"5"}}`)
+	if res.Ok() || res[0].Pointer() != "/orders/0/price" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `res[0].Pointer()`, "/orders/0/price", res)
+	}
+}
@@ -0,0 +1,267 @@
+package objpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PseudoProperty computes a property of obj that is
+// not one of its children, like $length.
+// ok is false if obj does not have the property.
+type PseudoProperty func(obj Object) (prop Object, ok bool)
+
+// pseudoProperties are usable both in paths
+// and as assertion keys, e.g. a.$length, {"$type":"list"}.
+// A real child with the same key takes precedence.
+var pseudoProperties = map[string]PseudoProperty{
+	"$length": pseudoLength,
+	"$keys":   pseudoKeys,
+	"$values": pseudoValues,
+	"$type":   pseudoType,
+	"$first":  pseudoFirst,
+	"$last":   pseudoLast,
+	"$key":    pseudoKey,
+	"$index":  pseudoIndex,
+}
+
+// RegisterPseudoProperty adds or replaces a pseudo property,
+// name must start with '$'. It should be called during
+// initialization, it is not safe to call concurrently
+// with queries.
+func RegisterPseudoProperty(name string, prop PseudoProperty) {
+	if !strings.HasPrefix(name, "$") || len(name) == 1 {
+		panic("pseudo property must start with '$': " + name)
+	}
+	pseudoProperties[name] = prop
+}
+
+// lookupPseudoProperty finds pseudo property by name
+func lookupPseudoProperty(name string) (PseudoProperty, bool) {
+	if !strings.HasPrefix(name, "$") {
+		return nil, false
+	}
+	prop, ok := pseudoProperties[name]
+	return prop, ok
+}
+
+// isPseudoPath tells whether path starts with a
+// pseudo property, like $length, $keys.0
+func isPseudoPath(path string) bool {
+	name := path
+	if idx := strings.IndexAny(path, ".[{("); idx >= 0 {
+		name = path[:idx]
+	}
+	_, ok := lookupPseudoProperty(name)
+	return ok
+}
+
+func newIntPrimitive(n int) Primitive {
+	return NewPrimitve(n, strconv.FormatInt(int64(n), 10))
+}
+
+// $length: length of string, or number of children
+func pseudoLength(obj Object) (Object, bool) {
+	switch obj := obj.(type) {
+	case Primitive:
+		return newIntPrimitive(len(obj.StrValue())), true
+	case Composite:
+		return newIntPrimitive(obj.ChildrenLen()), true
+	}
+	return nil, false
+}
+
+// $keys: list of children keys
+func pseudoKeys(obj Object) (Object, bool) {
+	comp, ok := obj.(Composite)
+	if !ok {
+		return nil, false
+	}
+	keys := make([]string, 0, comp.ChildrenLen())
+	comp.RangeChildren(func(key string, child Object) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return NewObject(keys), true
+}
+
+// $values: list of children values
+func pseudoValues(obj Object) (Object, bool) {
+	comp, ok := obj.(Composite)
+	if !ok {
+		return nil, false
+	}
+	values := &valueList{list: make([]Object, 0, comp.ChildrenLen())}
+	comp.RangeChildren(func(key string, child Object) bool {
+		values.list = append(values.list, child)
+		return true
+	})
+	return values, true
+}
+
+// valueList is the list of $values, the children keep
+// their own location, so that JSON objects and arrays
+// stay Composite instead of their JSON text
+type valueList struct {
+	list []Object
+	loc
+}
+
+var _ Composite = ((*valueList)(nil))
+
+// Value implements Object
+func (c *valueList) Value() interface{} {
+	values := make([]interface{}, len(c.list))
+	for i, child := range c.list {
+		values[i] = child.Value()
+	}
+	return values
+}
+
+// Method implements Object, valueList has no methods
+func (c *valueList) Method(name string) (method interface{}, ok bool) {
+	return nil, false
+}
+
+// ChildrenLen implements Composite
+func (c *valueList) ChildrenLen() int {
+	return len(c.list)
+}
+
+// GetChild implements Composite
+func (c *valueList) GetChild(key string) (child Object, ok bool) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i >= len(c.list) {
+		return nil, false
+	}
+	return c.list[i], true
+}
+
+// RangeChildren implements Composite
+func (c *valueList) RangeChildren(fn func(key string, child Object) bool) {
+	for i, child := range c.list {
+		if !fn(strconv.Itoa(i), child) {
+			return
+		}
+	}
+}
+
+func (c *valueList) getChildren() []Object {
+	return c.list
+}
+
+func (c *valueList) String() string {
+	strs := make([]string, len(c.list))
+	for i, child := range c.list {
+		strs[i] = fmt.Sprint(child)
+	}
+	return "[" + strings.Join(strs, " ") + "]"
+}
+
+// $type: one of string,number,bool,null,list,map,struct
+func pseudoType(obj Object) (Object, bool) {
+	t := typeOf(obj)
+	return NewPrimitve(t, t), true
+}
+
+func typeOf(obj Object) string {
+	switch obj := deref(obj).(type) {
+	case nil, *Null:
+		return "null"
+	case *List, *JSONList, *valueList:
+		return "list"
+	case *Map, *JSONMap:
+		return "map"
+	case *Struct:
+		return "struct"
+	case Composite:
+		return "map"
+	case Primitive:
+		v := obj.Value()
+		if v == nil {
+			return "null"
+		}
+		if _, ok := v.(json.Number); ok {
+			return "number"
+		}
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.String:
+			return "string"
+		case reflect.Bool:
+			return "bool"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return "number"
+		}
+		return "string"
+	}
+	return "null"
+}
+
+// $first: the first child
+func pseudoFirst(obj Object) (Object, bool) {
+	comp, ok := obj.(Composite)
+	if !ok {
+		return nil, false
+	}
+	var first Object
+	found := false
+	comp.RangeChildren(func(key string, child Object) bool {
+		first = child
+		found = true
+		return false
+	})
+	return first, found
+}
+
+// $last: the last child
+func pseudoLast(obj Object) (Object, bool) {
+	if list, ok := asList(obj); ok {
+		children := list.getChildren()
+		if len(children) == 0 {
+			return nil, false
+		}
+		return children[len(children)-1], true
+	}
+	comp, ok := obj.(Composite)
+	if !ok {
+		return nil, false
+	}
+	var last Object
+	found := false
+	comp.RangeChildren(func(key string, child Object) bool {
+		last = child
+		found = true
+		return true
+	})
+	return last, found
+}
+
+// $key: key of obj in its parent
+func pseudoKey(obj Object) (Object, bool) {
+	l, ok := obj.(Located)
+	if !ok || l.Parent() == nil {
+		return nil, false
+	}
+	key := l.Key()
+	return NewPrimitve(key, key), true
+}
+
+// $index: index of obj in its parent list
+func pseudoIndex(obj Object) (Object, bool) {
+	l, ok := obj.(Located)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := asList(l.Parent()); !ok {
+		return nil, false
+	}
+	i, err := strconv.Atoi(l.Key())
+	if err != nil {
+		return nil, false
+	}
+	return newIntPrimitive(i), true
+}
@@ -0,0 +1,126 @@
+package objpath
+
+import (
+	"fmt"
+	"testing"
+)
+
+// go test -run TestQueryPseudoProperties -v ./
+func TestQueryPseudoProperties(t *testing.T) {
+	type User struct {
+		Name string
+		Age  int
+	}
+	v := map[string]interface{}{
+		"list":  []string{"a", "bb", "ccc"},
+		"user":  User{Name: "x", Age: 10},
+		"empty": []int{},
+		"n":     1.5,
+		"b":     true,
+	}
+	cases := map[string]string{
+		"list.$length":          `[3]`,
+		"list.1.$length":        `[2]`,
+		"list.$first":           `[a]`,
+		"list.$last":            `[ccc]`,
+		"list.$keys":            `[[0 1 2]]`,
+		"list.$values":          `[[a bb ccc]]`,
+		"list.*{$index>0}":      `[bb ccc]`,
+		"list.$last.$index":     `[2]`,
+		"user.$keys":            `[[Name Age]]`,
+		"user.$values":          `[[x 10]]`,
+		"user.$first":           `[x]`,
+		"user.$last":            `[10]`,
+		"user.*{$key=Age}":      `[10]`,
+		"user.Name.$key":        `[Name]`,
+		"user.Name.$index":      `[]`,
+		"empty.$first":          `[]`,
+		"empty.$last":           `[]`,
+		"list.$type":            `[list]`,
+		"user.$type":            `[struct]`,
+		"$type":                 `[map]`,
+		"n.$type":               `[number]`,
+		"b.$type":               `[bool]`,
+		"user.Name.$type":       `[string]`,
+		"$key":                  `[]`,
+		"list.$values.$length":  `[3]`,
+		"user.$keys[-1].$index": `[1]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+}
+
+// go test -run TestQueryPseudoPropertyKeys -v ./
+func TestQueryPseudoPropertyKeys(t *testing.T) {
+	v := map[string]interface{}{
+		"a": map[string]interface{}{"$type": "x", "$first": 1},
+	}
+	cases := map[string]string{
+		"a.$type":       `[x]`,
+		"a.'$type'":     `[x]`,
+		"a.$first":      `[1]`,
+		"a.$length":     `[2]`,
+		"a.$type.$type": `[string]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+	AssertT(t, v, `{"a":{"$type":"x","$length":2}}`)
+}
+
+// go test -run TestRegisterPseudoProperty -v ./
+func TestRegisterPseudoProperty(t *testing.T) {
+	RegisterPseudoProperty("$double", func(obj Object) (Object, bool) {
+		prim, ok := obj.(Primitive)
+		if !ok {
+			return nil, false
+		}
+		s := prim.StrValue() + prim.StrValue()
+		return NewPrimitve(s, s), true
+	})
+	defer delete(pseudoProperties, "$double")
+
+	AssertT(t, map[string]interface{}{"a": "x"}, `{"a.$double":"xx", "a":{"$double":"xx"}}`)
+}
+
+// go test -run TestFilterPseudoProperties -v ./
+func TestFilterPseudoProperties(t *testing.T) {
+	testAssert(t,
+		map[string]interface{}{
+			"events": []interface{}{
+				map[string]interface{}{"type": "start"},
+				map[string]interface{}{"type": "done"},
+			},
+		},
+		`{
+			"events":{
+				"$type":"list",
+				"$length":{"$gt":"1"},
+				"$last.type":"done",
+				"$keys.$length":"2"
+			},
+			"events.$first":{
+				"$key":"0",
+				"type":"start"
+			}
+		}`,
+		`{
+			"$length":"1"
+		}`,
+	)
+}
@@ -7,23 +7,29 @@ func Query(v interface{}, path string) ([]Object, error) {
 	return QueryObjects([]Object{NewObject(v)}, path)
 }
 
+// QueryWithOptions is like Query, but creates
+// the Object of v with opts
+func QueryWithOptions(v interface{}, path string, opts *Options) ([]Object, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return QueryObjects([]Object{NewObjectWithOptions(v, opts)}, path)
+}
+
 func QueryObject(v Object, path string) ([]Object, error) {
 	return QueryObjects([]Object{v}, path)
 }
+
+// QueryObjects compiles path and query it against v,
+// compiled paths are cached, use CompilePath to
+// validate or hold a path explicitly.
 func QueryObjects(v []Object, path string) ([]Object, error) {
 	if len(v) == 0 {
 		return nil, nil
 	}
-	exprs, err := parsePath(path)
+	p, err := compilePathCached(path)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, expr := range exprs {
-		v = expr.Filter(v)
-		if len(v) == 0 {
-			return nil, nil
-		}
-	}
-	return v, nil
+	return p.QueryObjects(v)
 }
@@ -2,6 +2,8 @@ package objpath
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -24,3 +26,622 @@ func TestQuerySimple(t *testing.T) {
 		t.Fatalf("expect %s = %+v, actual:%+v", `s`, expect, s)
 	}
 }
+
+// go test -run TestQueryRecursiveDescent -v ./
+func TestQueryRecursiveDescent(t *testing.T) {
+	v, err := Query(map[string]interface{}{
+		"order": map[string]interface{}{
+			"id": 1,
+			"items": []interface{}{
+				map[string]interface{}{"id": 2},
+				map[string]interface{}{"id": 3, "sub": map[string]interface{}{"id": 4}},
+			},
+		},
+		"id": 0,
+	}, "order..id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, o := range v {
+		ids = append(ids, o.(Primitive).StrValue())
+	}
+	sort.Strings(ids)
+
+	s := fmt.Sprintf("%v", ids)
+	expect := `[1 2 3 4]`
+	if s != expect {
+		t.Fatalf("expect %s = %+v, actual:%+v", `s`, expect, s)
+	}
+}
+
+// go test -run TestQueryRecursiveDescentBadSyntax -v ./
+func TestQueryRecursiveDescentBadSyntax(t *testing.T) {
+	for _, path := range []string{"a..", "a...b"} {
+		_, err := Query(map[string]interface{}{}, path)
+		if err == nil {
+			t.Fatalf("expect %s to fail", path)
+		}
+	}
+}
+
+// go test -run TestQueryListIndexAndSlice -v ./
+func TestQueryListIndexAndSlice(t *testing.T) {
+	v := map[string]interface{}{
+		"items": []int{0, 1, 2, 3, 4, 5},
+	}
+	cases := map[string]string{
+		"items[-1]":     `[5]`,
+		"items[-6]":     `[0]`,
+		"items[-7]":     `[]`,
+		"items[1:4]":    `[1 2 3]`,
+		"items[::2]":    `[0 2 4]`,
+		"items[-2:]":    `[4 5]`,
+		"items[::-2]":   `[5 3 1]`,
+		"items[4:1:-1]": `[4 3 2]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+	_, err := Query(v, "items[::0]")
+	if err == nil {
+		t.Fatalf("expect zero step to fail")
+	}
+}
+
+// go test -run TestQueryUnion -v ./
+func TestQueryUnion(t *testing.T) {
+	v := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":  "alice",
+			"email": "alice@example.com",
+			"age":   20,
+		},
+		"list": []string{"a", "b", "c", "d", "e", "f"},
+	}
+	cases := map[string]string{
+		"user.[email,name]": `[alice@example.com alice]`,
+		"user[name, age]":   `[alice 20]`,
+		"list[0,2,5]":       `[a c f]`,
+		"list[-1,0:2]":      `[f a b]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+}
+
+// go test -run TestQueryQuotedKeys -v ./
+func TestQueryQuotedKeys(t *testing.T) {
+	v := map[string]interface{}{
+		"http://x.com/a": "url",
+		"k*":             "star",
+		"kx":             "kx",
+		"m{1}":           "brace",
+		"m[0]":           "bracket",
+		"cpu.load": map[string]interface{}{
+			"a'b": "quote",
+		},
+		"list": []interface{}{
+			map[string]interface{}{"name": "a,b"},
+			map[string]interface{}{"name": "c}"},
+		},
+	}
+	cases := map[string]string{
+		`["http://x.com/a"]`:      `[url]`,
+		`'k*'`:                    `[star]`,
+		`k\*`:                     `[star]`,
+		`"m{1}"`:                  `[brace]`,
+		`['m[0]']`:                `[bracket]`,
+		`m\[0\]`:                  `[bracket]`,
+		`cpu\.load.["a'b"]`:       `[quote]`,
+		`'cpu.load'.'a\'b'`:       `[quote]`,
+		`["k*",kx]`:               `[star kx]`,
+		`list.*{name="a,b"}.name`: `[a,b]`,
+		`list.*{name='c}'}.name`:  `[c}]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+	for _, path := range []string{`'abc`, `["abc]`, `'a'b`, `a\`} {
+		_, err := Query(v, path)
+		if err == nil {
+			t.Fatalf("expect %s to fail", path)
+		}
+	}
+}
+
+// go test -run TestCompilePath -v ./
+func TestCompilePath(t *testing.T) {
+	p := MustCompilePath("a.b[-1]")
+	if p.String() != "a.b[-1]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `p.String()`, "a.b[-1]", p.String())
+	}
+	for _, v := range []interface{}{
+		map[string]interface{}{"a": map[string]interface{}{"b": []int{1, 2}}},
+		map[string]interface{}{"a": map[string]interface{}{"b": []int{3}}},
+	} {
+		res, err := p.Query(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 {
+			t.Fatalf("expect %s = %+v, actual:%+v", `len(res)`, 1, len(res))
+		}
+	}
+	_, err := CompilePath("a.b[")
+	if _, ok := err.(*PathSyntaxError); !ok {
+		t.Fatalf("expect *PathSyntaxError, actual: %v", err)
+	}
+}
+
+// go test -run TestPathCache -v ./
+func TestPathCache(t *testing.T) {
+	c := newPathCache(2)
+	c.Add("a", MustCompilePath("a"))
+	c.Add("b", MustCompilePath("b"))
+	c.Get("a")
+	c.Add("c", MustCompilePath("c"))
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expect b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expect a to be kept")
+	}
+	if c.Len() != 2 {
+		t.Fatalf("expect %s = %+v, actual:%+v", `c.Len()`, 2, c.Len())
+	}
+
+	p1, _ := compilePathCached("x.y.z")
+	p2, _ := compilePathCached("x.y.z")
+	if p1 != p2 {
+		t.Fatalf("expect compiled path to be reused")
+	}
+}
+
+// go test -run TestQueryConditionOperators -v ./
+func TestQueryConditionOperators(t *testing.T) {
+	type Item struct {
+		Name    string
+		Price   float64
+		Tag     string
+		Deleted *bool
+	}
+	yes := true
+	v := map[string]interface{}{
+		"items": []Item{
+			{Name: "foo1", Price: 5, Tag: "x"},
+			{Name: "foo2", Price: 15, Tag: "y"},
+			{Name: "bar", Price: 20, Tag: "y", Deleted: &yes},
+			{Name: "foo3", Price: 30, Tag: "x"},
+		},
+	}
+	cases := map[string]string{
+		"items.*{Price>10,Name^=foo,Tag!=x}.Name": `[foo2]`,
+		"items.*{Price>=15,Price<=20}.Name":       `[foo2 bar]`,
+		"items.*{Price<15}.Name":                  `[foo1]`,
+		"items.*{Name$=3}.Name":                   `[foo3]`,
+		"items.*{Name*=oo}.Name":                  `[foo1 foo2 foo3]`,
+		"items.*{Deleted}.Name":                   `[bar]`,
+		"items.*{!Deleted,Tag=y}.Name":            `[foo2]`,
+		"items.*{!Name^=foo}.Name":                `[bar]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+}
+
+// go test -run TestQueryConditionLogic -v ./
+func TestQueryConditionLogic(t *testing.T) {
+	v := map[string]interface{}{
+		"orders": []interface{}{
+			map[string]interface{}{"id": "1", "status": "paid", "retries": 0},
+			map[string]interface{}{"id": "2", "status": "pending", "retries": 5},
+			map[string]interface{}{"id": "3", "status": "pending", "retries": 1},
+			map[string]interface{}{"id": "4", "status": "failed", "retries": 9},
+		},
+	}
+	cases := map[string]string{
+		"orders.*{status=paid || (status=pending && retries>3)}.id": `[1 2]`,
+		"orders.*{status=paid||status=pending,retries>3}.id":        `[1 2]`,
+		"orders.*{(status=paid||status=pending)&&retries>0}.id":     `[2 3]`,
+		"orders.*{!(status=paid||status=failed)}.id":                `[2 3]`,
+		"orders.*{!!(status=paid)}.id":                              `[1]`,
+		"orders.*{status='a||b' || id=4}.id":                        `[4]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+	for _, path := range []string{"orders.*{(status=paid}", "orders.*{status=paid||}", "orders.*{&&id=1}"} {
+		_, err := Query(v, path)
+		if _, ok := err.(*PathSyntaxError); !ok {
+			t.Fatalf("%s: expect *PathSyntaxError, actual: %v", path, err)
+		}
+	}
+}
+
+type testUser struct {
+	First string
+	Last  string
+}
+
+func (c testUser) FullName() string {
+	return c.First + " " + c.Last
+}
+
+type testCache map[string]string
+
+func (c testCache) Get(k string) string {
+	return c[k]
+}
+
+func (c testCache) Lookup(n int, suffix string, upper bool) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("negative n: %d", n)
+	}
+	s := fmt.Sprintf("%d%s", n, suffix)
+	if upper {
+		s = strings.ToUpper(s)
+	}
+	return s, nil
+}
+
+func (c testCache) Pair() (string, string) {
+	return "a", "b"
+}
+
+func (c testCache) Panic() string {
+	panic("boom")
+}
+
+// go test -run TestQueryMethodCall -v ./
+func TestQueryMethodCall(t *testing.T) {
+	v := map[string]interface{}{
+		"user":  testUser{First: "Ada", Last: "Lovelace"},
+		"cache": testCache{"k": "v", "x,y": "z"},
+		"users": []testUser{{First: "A", Last: "B"}, {First: "C", Last: "D"}},
+	}
+	cases := map[string]string{
+		`user.FullName()`:                 `[Ada Lovelace]`,
+		`cache.Get("k")`:                  `[v]`,
+		`cache.Get('x,y')`:                `[z]`,
+		`cache.Lookup(3, "x", false)`:     `[3x]`,
+		`cache.Lookup( 3 ,"x",true )`:     `[3X]`,
+		`users.*{FullName()="C D"}.First`: `[C]`,
+		`cache.Missing()`:                 `[]`,
+		`users.*.FullName(){$length>=3}`:  `[A B C D]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+
+	errCases := map[string]string{
+		`cache.Lookup(-1, "x", true)`:  "negative n: -1",
+		`cache.Lookup("a", "x", true)`: "parsing arg 0",
+		`cache.Get()`:                  "expecting 1 args",
+		`cache.Pair()`:                 "more than 1 result",
+		`cache.Panic()`:                "panic: boom",
+	}
+	for path, expect := range errCases {
+		_, err := Query(v, path)
+		if err == nil || !strings.Contains(err.Error(), expect) {
+			t.Fatalf("%s: expect error %q, actual: %v", path, expect, err)
+		}
+	}
+	for _, path := range []string{`cache.Get(k)`, `cache.Get("k"`, `cache.Get("k",)`} {
+		_, err := Query(v, path)
+		if _, ok := err.(*PathSyntaxError); !ok {
+			t.Fatalf("%s: expect *PathSyntaxError, actual: %v", path, err)
+		}
+	}
+}
+
+type testShapes struct{}
+
+func (c testShapes) Area(p testPoint, scale float64) float64 {
+	return float64(p.X*p.Y) * scale
+}
+
+func (c testShapes) Sum(l []int) int {
+	n := 0
+	for _, i := range l {
+		n += i
+	}
+	return n
+}
+
+// go test -run TestQueryMethodCallJSONArgs -v ./
+func TestQueryMethodCallJSONArgs(t *testing.T) {
+	v := map[string]interface{}{
+		"shapes": testShapes{},
+	}
+	cases := map[string]string{
+		`shapes.Area({"x":2,"y":"}"}, 1.5)`: ``,
+		`shapes.Area({"x":2,"y":3}, 1.5)`:   `[9]`,
+		`shapes.Sum([1,[2],3])`:             ``,
+		`shapes.Sum([1, 2, 3])`:             `[6]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if expect == "" {
+			if err == nil || !strings.Contains(err.Error(), "parsing json") {
+				t.Fatalf("%s: expect json error, actual: %v", path, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+}
+
+type testOpaque struct{}
+
+func (c testOpaque) Value() interface{} {
+	return nil
+}
+
+func (c testOpaque) Method(name string) (interface{}, bool) {
+	return nil, false
+}
+
+// go test -run TestQueryError -v ./
+func TestQueryError(t *testing.T) {
+	v := map[string]interface{}{
+		"a": map[string]interface{}{
+			"cache": testCache{},
+		},
+	}
+	_, err := Query(v, `a.cache.Lookup(-1, "x", true).b`)
+	qerr, ok := err.(*QueryError)
+	if !ok {
+		t.Fatalf("expect *QueryError, actual: %T %v", err, err)
+	}
+	if qerr.Segment != `Lookup(-1, "x", true)` {
+		t.Fatalf("expect %s = %+v, actual:%+v", `qerr.Segment`, `Lookup(-1, "x", true)`, qerr.Segment)
+	}
+	if qerr.ObjectPath != "a.cache" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `qerr.ObjectPath`, "a.cache", qerr.ObjectPath)
+	}
+	if !strings.Contains(qerr.Cause.Error(), "negative n: -1") {
+		t.Fatalf("expect cause to be negative n, actual: %v", qerr.Cause)
+	}
+
+	// the concrete object, not the pattern before the segment
+	v["items"] = []interface{}{1, "x", testCache{}}
+	_, err = Query(v, `items.*.Pair()`)
+	qerr, ok = err.(*QueryError)
+	if !ok {
+		t.Fatalf("expect *QueryError, actual: %T %v", err, err)
+	}
+	if qerr.ObjectPath != "items.2" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `qerr.ObjectPath`, "items.2", qerr.ObjectPath)
+	}
+	_, err = Query(v, `items.*{Pair()=a}`)
+	qerr, ok = err.(*QueryError)
+	if !ok || qerr.ObjectPath != "items.2" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `qerr.ObjectPath`, "items.2", err)
+	}
+
+	_, err = QueryObject(testOpaque{}, "a")
+	if _, ok := err.(*QueryError); !ok || !strings.Contains(err.Error(), "unhandled object type") {
+		t.Fatalf("expect unhandled object type, actual: %v", err)
+	}
+}
+
+// go test -run TestFilterQueryError -v ./
+func TestFilterQueryError(t *testing.T) {
+	res := Check(map[string]interface{}{"cache": testCache{}}, `{"cache.Pair()":"a"}`)
+	if len(res) != 1 {
+		t.Fatalf("expect 1 fail detail, actual: %v", res)
+	}
+	if res[0].Field != "cache.Pair()" || !strings.Contains(res[0].QueryError, "more than 1 result") {
+		t.Fatalf("expect query error, actual: %v", res)
+	}
+}
+
+// go test -run TestQueryParentRoot -v ./
+func TestQueryParentRoot(t *testing.T) {
+	v := map[string]interface{}{
+		"customer": "ada",
+		"limit":    2,
+		"order": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "a", "qty": 0},
+				map[string]interface{}{"name": "b", "qty": 3},
+				map[string]interface{}{"name": "c", "qty": 1},
+			},
+		},
+		"user": testUser{First: "Ada", Last: "Lovelace"},
+	}
+	cases := map[string]string{
+		`order.items.*{qty>0}.^.^.^.customer`:  `[ada]`,
+		`order.items.*{qty>0}.$.customer`:      `[ada]`,
+		`order.items.*.^.$length`:              `[3]`,
+		`order.items.*{qty>0}.name`:            `[b c]`,
+		`order.items.*{$.limit=2,qty>=2}.name`: `[b]`,
+		`order.items.*{^.$length=3}.name`:      `[a b c]`,
+		`order.items[1].^{$length=3}.$type`:    `[list]`,
+		`order.items[1].^{$length=2}`:          `[]`,
+		`$.customer`:                           `[ada]`,
+		`customer.^.^`:                         `[]`,
+		`user.FullName().^.First`:              `[Ada]`,
+		`order.items.$first.^.^.$key`:          `[order]`,
+		`order..name{^.qty=1}`:                 `[c]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		s := fmt.Sprintf("%v", res)
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+
+	// escaped or quoted ^ and $ are keys
+	res, err := Query(map[string]interface{}{"^": 1, "$": 2}, `['^','$']`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprintf("%v", res); s != "[1 2]" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `s`, "[1 2]", s)
+	}
+}
+
+// go test -run TestParentOf -v ./
+func TestParentOf(t *testing.T) {
+	root := NewObject(map[string]interface{}{"a": []int{1, 2}})
+	objs, err := QueryObject(root, "a[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := objs[0].(Located)
+	if !ok {
+		t.Fatalf("expect %s = %+v, actual:%+v", `ok`, true, ok)
+	}
+	if l.Key() != "1" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `l.Key()`, "1", l.Key())
+	}
+	if ParentOf(l.Parent()) != root {
+		t.Fatalf("expect %s = %+v, actual:%+v", `ParentOf(l.Parent())`, root, ParentOf(l.Parent()))
+	}
+	if RootOf(objs[0]) != root {
+		t.Fatalf("expect %s = %+v, actual:%+v", `RootOf(objs[0])`, root, RootOf(objs[0]))
+	}
+	if ParentOf(root) != nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", `ParentOf(root)`, nil, ParentOf(root))
+	}
+}
+
+// go test -run TestQueryRegexKey -v ./
+func TestQueryRegexKey(t *testing.T) {
+	v := map[string]interface{}{
+		"data": map[string]interface{}{
+			"x_1":     map[string]interface{}{"n": 1},
+			"x_22":    map[string]interface{}{"n": 2},
+			"y_3":     map[string]interface{}{"n": 3},
+			"x_abc":   map[string]interface{}{"n": 4},
+			"a/b":     map[string]interface{}{"n": 5},
+			"pre_x_1": map[string]interface{}{"n": 6},
+		},
+	}
+	cases := map[string]string{
+		`data.~/^x_\d+$/.n`:           `[1 2]`,
+		`data.~/^(x|y)_\d$/.n`:        `[1 3]`,
+		`data.~/^a\/b$/.n`:            `[5]`,
+		`data.~/x_1/.n`:               `[1 6]`,
+		`data.~/^x_/{n>1}.n`:          `[2 4]`,
+		`data.*{~/^n$/>4}.n`:          `[5 6]`,
+		`data.~/^x_\d+$/{n=2}{n<3}.n`: `[2]`,
+		`data.~/(?i)^X_ABC$/.n`:       `[4]`,
+		`data.'~/x/'`:                 `[]`,
+	}
+	for path, expect := range cases {
+		res, err := Query(v, path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		var ns []int
+		for _, r := range res {
+			ns = append(ns, r.Value().(int))
+		}
+		sort.Ints(ns)
+		s := fmt.Sprintf("%v", ns)
+		if ns == nil {
+			s = "[]"
+		}
+		if s != expect {
+			t.Fatalf("%s: expect %s = %+v, actual:%+v", path, `s`, expect, s)
+		}
+	}
+
+	for _, path := range []string{`data.~/(/`, `data.~/abc`} {
+		_, err := Query(v, path)
+		if _, ok := err.(*PathSyntaxError); !ok {
+			t.Fatalf("%s: expect *PathSyntaxError, actual: %v", path, err)
+		}
+	}
+}
+
+// go test -run TestGlobMatch -v ./
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		key    string
+		glob   string
+		expect bool
+	}{
+		{"abc", "abc", true},
+		{"abc", "a*", true},
+		{"abc", "*c", true},
+		{"abc", "*b*", true},
+		{"abc", "*d*", false},
+		{"a/b", "*", true},
+		{"a/b", "a?b", true},
+		{"a/b/c", "a*c", true},
+		{"", "*", true},
+		{"", "", true},
+		{"a", "", false},
+		{"abcbc", "a*bc", true},
+		{"abcbd", "a*bc", false},
+		{"k*", `k\*`, true},
+		{"kx", `k\*`, false},
+		{"b1", "[a-c][0-9]", true},
+		{"d1", "[a-c][0-9]", false},
+		{"d1", "[^a-c]1", true},
+		{"世界", "?界", true},
+		{"a", "[a", false},
+		{"a", `a\`, false},
+	}
+	for _, c := range cases {
+		if globMatch(c.key, c.glob) != c.expect {
+			t.Fatalf("globMatch(%q, %q): expect %v", c.key, c.glob, c.expect)
+		}
+	}
+}
@@ -25,29 +25,9 @@ func (c *SortedMap) Add(key string, val interface{}) {
 	c.m[key] = val
 }
 func (c *SortedMap) Set(key string, val interface{}) {
-	if _, ok := c.m[key]; ok {
-		idx := -1
-		for i, k := range c.keys {
-			if k == key {
-				idx = i
-				break
-			}
-		}
-		if idx < 0 {
-			panic(fmt.Errorf("inconsistent key not found:%v", key))
-		}
-
-		// adjust key in the middle way
-		keys := c.keys
-		c.keys = make([]string, 0, len(c.keys))
-		c.keys = append(c.keys, keys[0:idx]...)
-		if idx+1 < len(keys) {
-			c.keys = append(c.keys, keys[idx+1:]...)
-		}
-		c.m[key] = val
-		return
+	if _, ok := c.m[key]; !ok {
+		c.keys = append(c.keys, key)
 	}
-	c.keys = append(c.keys, key)
 	c.m[key] = val
 }
 
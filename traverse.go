@@ -54,10 +54,11 @@ func GetExportedJSONName(fieldType *reflect.StructField) (jsonName string, omitE
 			}
 		}
 	}
-	if jsonName == "-" {
+	if jsonTag == "-" {
 		jsonName = ""
 		return // ignored
 	}
+	// "-," means the name is "-"
 	// omit empty
 	if jsonName == "" {
 		jsonName = fieldName